@@ -1,33 +1,98 @@
 package handlers
 
 import (
+	"github.com/farmani/service/business/core/event"
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/cview/user/summary"
 	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/idempotency"
+	"github.com/farmani/service/business/web/ratelimit"
+	"github.com/farmani/service/business/web/session"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/farmani/service/foundation/web"
 
+	v1 "github.com/farmani/service/app/services/sales-api/handlers/v1"
 	"github.com/farmani/service/app/services/sales-api/handlers/v1/testgrp"
 	"github.com/farmani/service/business/web/v1/middlewares"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 // APIMuxConfig contains all the mandatory systems required by handlers.
 type APIMuxConfig struct {
-	Build    string
-	Shutdown chan os.Signal
-	Log      *zap.SugaredLogger
-	Auth     *auth.Auth
+	Build        string
+	Shutdown     chan os.Signal
+	Log          *zap.SugaredLogger
+	Auth         *auth.Auth
+	Sessions     *session.Manager
+	Tracer       trace.Tracer
+	RateLimiter  *ratelimit.Limiter
+	Idempotency  *idempotency.Manager
+	Events       *event.Broadcaster
+	Heartbeat    time.Duration
+	UserCore     *user.Core
+	ProductCore  *product.Core
+	SummaryCore  *summary.Core
+	CORS         middlewares.CORSConfig
+	Security     middlewares.SecurityConfig
+	CompressMin  int
+	Timeouts     web.Timeouts
+	LoadShed     web.LoadShedding
+	LegacyErrors bool
 }
 
 // APIMux constructs a http.Handler with all application routes defined.
 func APIMux(cfg APIMuxConfig) *web.App {
 
-	mux := web.NewApp(cfg.Shutdown, middlewares.Logger(cfg.Log), middlewares.Errors(cfg.Log), middlewares.Metrics(), middlewares.Panics())
+	mux := web.NewApp(cfg.Shutdown, cfg.Tracer, middlewares.Logger(cfg.Log), middlewares.SecurityHeaders(cfg.Security), middlewares.Errors(cfg.Log, cfg.LegacyErrors), middlewares.Metrics(), middlewares.Panics())
 
-	mux.Handle(http.MethodGet, "/test", testgrp.Test)
-	mux.Handle(http.MethodGet, "/test/auth", testgrp.Test, middlewares.Authenticate(cfg.Auth), middlewares.Authorize(cfg.Auth, auth.RuleAdminOnly))
+	// Excess load is shed before the deadlines start, so rejected requests
+	// don't count against them.
+	mux.EnableLoadShedding(cfg.LoadShed)
+	mux.EnableTimeouts(cfg.Timeouts)
+
+	// Responses are only compressed when a minimum size is configured.
+	if cfg.CompressMin > 0 {
+		mux.EnableCompression(cfg.CompressMin)
+	}
+
+	// Cross origin requests are only answered when origins are configured.
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		mux.EnableCORS(middlewares.CORS(cfg.CORS))
+	}
+
+	limit := middlewares.RateLimit(cfg.RateLimiter)
+
+	// Browser clients can use a session cookie in place of a bearer token
+	// when session support is configured.
+	authen := middlewares.Authenticate(cfg.Auth)
+	if cfg.Sessions != nil {
+		authen = middlewares.AuthenticateSession(cfg.Auth, cfg.Sessions)
+	}
+
+	// Each version of the api is mounted under its own prefix so a newer
+	// version can be served side by side with the older ones.
+	v1.Routes(mux, v1.Config{
+		Build:       cfg.Build,
+		Log:         cfg.Log,
+		Auth:        cfg.Auth,
+		Sessions:    cfg.Sessions,
+		RateLimiter: cfg.RateLimiter,
+		Idempotency: cfg.Idempotency,
+		Events:      cfg.Events,
+		Heartbeat:   cfg.Heartbeat,
+		UserCore:    cfg.UserCore,
+		ProductCore: cfg.ProductCore,
+		SummaryCore: cfg.SummaryCore,
+	})
+
+	mux.Handle(http.MethodGet, "/test", testgrp.Test, limit)
+	mux.Handle(http.MethodGet, "/test/auth", testgrp.Test, authen, limit, middlewares.Authorize(cfg.Auth, auth.RuleAdminOnly))
 
 	return mux
 }
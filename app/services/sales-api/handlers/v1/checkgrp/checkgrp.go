@@ -8,15 +8,17 @@ import (
 	"time"
 
 	database "github.com/farmani/service/business/sys/database/pgx"
+	"github.com/farmani/service/business/web/lifecycle"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
 
 // Handlers manages the set of check endpoints.
 type Handlers struct {
-	Build string
-	Log   *zap.SugaredLogger
-	DB    *sqlx.DB
+	Build     string
+	Log       *zap.SugaredLogger
+	DB        *sqlx.DB
+	Lifecycle *lifecycle.Manager
 }
 
 // New constructs a Handlers api for the check group.
@@ -28,8 +30,9 @@ func New(build string, log *zap.SugaredLogger) *Handlers {
 }
 
 // Readiness checks if the database is ready and if not will return a 500 status.
-// Do not respond by just returning an error because further up in the call
-// stack it will interpret that as a non-trusted error.
+// Once the service is shutting down a 503 status is returned so no new traffic
+// is routed to it. Do not respond by just returning an error because further
+// up in the call stack it will interpret that as a non-trusted error.
 func (h *Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
 	defer cancel()
@@ -37,9 +40,16 @@ func (h *Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
 	status := "ok"
 	statusCode := http.StatusOK
 
-	if err := database.StatusCheck(ctx, h.DB); err != nil {
-		status = "db not ready"
-		statusCode = http.StatusInternalServerError
+	switch {
+	case h.Lifecycle != nil && !h.Lifecycle.Ready():
+		status = "shutting down"
+		statusCode = http.StatusServiceUnavailable
+
+	default:
+		if err := database.StatusCheck(ctx, h.DB); err != nil {
+			status = "db not ready"
+			statusCode = http.StatusInternalServerError
+		}
 	}
 
 	data := struct {
@@ -94,6 +104,18 @@ func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
 	h.Log.Infow("liveness", "statusCode", statusCode, "method", r.Method, "path", r.URL.Path, "remoteaddr", r.RemoteAddr)
 }
 
+// Shutdown returns the progress of the shutdown of the service.
+func (h *Handlers) Shutdown(w http.ResponseWriter, r *http.Request) {
+	var status lifecycle.Status
+	if h.Lifecycle != nil {
+		status = h.Lifecycle.Status()
+	}
+
+	if err := response(w, http.StatusOK, status); err != nil {
+		h.Log.Errorw("shutdown", "ERROR", err)
+	}
+}
+
 func response(w http.ResponseWriter, statusCode int, data any) error {
 	jsonData, err := json.Marshal(data)
 	if err != nil {
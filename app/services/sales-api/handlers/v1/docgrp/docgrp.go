@@ -0,0 +1,47 @@
+// Package docgrp maintains the group of handlers for the api documentation.
+package docgrp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	v1 "github.com/farmani/service/business/web/v1"
+	"github.com/farmani/service/foundation/openapi"
+	"github.com/farmani/service/foundation/web"
+)
+
+// Handlers manages the set of documentation endpoints.
+type Handlers struct {
+	Build string
+	App   *web.App
+
+	once sync.Once
+	doc  *openapi.Document
+}
+
+// New constructs a Handlers api for the documentation group.
+func New(build string, app *web.App) *Handlers {
+	return &Handlers{
+		Build: build,
+		App:   app,
+	}
+}
+
+// OpenAPI returns the OpenAPI document describing the endpoints of the app.
+// The document is generated on the first request, once every route has been
+// registered.
+func (h *Handlers) OpenAPI(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	h.once.Do(func() {
+		info := openapi.Info{
+			Title:   "Sales API",
+			Version: h.Build,
+			Error:   v1.ErrorResponse{},
+			Problem: v1.Problem{},
+		}
+
+		h.doc = openapi.Generate(info, h.App.Endpoints())
+	})
+
+	return web.Respond(ctx, w, h.doc, http.StatusOK)
+}
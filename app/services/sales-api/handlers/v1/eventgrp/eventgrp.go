@@ -0,0 +1,118 @@
+// Package eventgrp maintains the group of handlers for streaming the changes
+// made to users and products.
+package eventgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/farmani/service/business/core/event"
+	"github.com/farmani/service/business/data/access"
+	v1 "github.com/farmani/service/business/web/v1"
+	"github.com/farmani/service/business/web/v1/middlewares"
+	"github.com/farmani/service/foundation/web"
+)
+
+// ErrInvalidEventID is returned when the ID of the last event received by a
+// reconnecting client can't be parsed.
+var ErrInvalidEventID = errors.New("last event ID is not in its proper form")
+
+// Handlers manages the set of event endpoints.
+type Handlers struct {
+	Events    *event.Broadcaster
+	Heartbeat time.Duration
+}
+
+// New constructs a handlers for route access.
+func New(events *event.Broadcaster, heartbeat time.Duration) *Handlers {
+	return &Handlers{
+		Events:    events,
+		Heartbeat: heartbeat,
+	}
+}
+
+// Stream sends the changes made to users and products as server-sent events.
+// Only the events for the entities the caller can list are sent, using the
+// access filter produced by AuthorizeQuery. A client reconnecting with the ID
+// of the last event it received is sent the events it missed first. When
+// they are no longer kept, a reset event is sent instead so the client knows
+// to reload its data. The stream ends when the client disconnects or falls
+// too far behind, in which case it can reconnect to resume.
+func (h *Handlers) Stream(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var after uint64
+	if id := web.LastEventID(r); id != "" {
+		var err error
+		after, err = strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return v1.NewRequestError(ErrInvalidEventID, http.StatusBadRequest)
+		}
+	}
+
+	filter := middlewares.GetAccessFilter(ctx)
+
+	sub, missed, complete := h.Events.Subscribe(after)
+	defer sub.Close()
+
+	es, err := web.NewEventStream(ctx, w)
+	if err != nil {
+		return fmt.Errorf("starting stream: %w", err)
+	}
+
+	if !complete {
+		reset := web.ServerEvent{
+			Name: "reset",
+			Data: AppReset{Message: "events were missed, reload the data"},
+		}
+		if err := es.Send(reset); err != nil {
+			return fmt.Errorf("sending reset: %w", err)
+		}
+	}
+
+	for _, evt := range missed {
+		if !visible(filter, evt) {
+			continue
+		}
+
+		if err := es.Send(toServerEvent(evt)); err != nil {
+			return fmt.Errorf("sending missed event: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan web.ServerEvent)
+	go func() {
+		defer close(events)
+
+		for evt := range sub.Events() {
+			if !visible(filter, evt) {
+				continue
+			}
+
+			select {
+			case events <- toServerEvent(evt):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	if err := es.Serve(ctx, events, h.Heartbeat); err != nil {
+		return fmt.Errorf("streaming: %w", err)
+	}
+
+	return nil
+}
+
+// visible reports whether the caller is allowed to see the event, based on
+// the user owning its entity.
+func visible(filter access.Filter, evt event.Event) bool {
+	return filter.Match(map[string]any{
+		"UserID": evt.UserID.String(),
+	})
+}
@@ -0,0 +1,117 @@
+package eventgrp
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/farmani/service/business/core/event"
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/foundation/web"
+)
+
+// AppEvent represents a change made to a user or a product sent on the
+// stream. Data holds the entity as it is after the change.
+type AppEvent struct {
+	Domain   string    `json:"domain"`
+	Action   string    `json:"action"`
+	EntityID string    `json:"entityId"`
+	UserID   string    `json:"userId"`
+	Data     any       `json:"data,omitempty"`
+	Date     time.Time `json:"date"`
+}
+
+// AppUser represents a user sent in an event.
+type AppUser struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Roles       []string  `json:"roles"`
+	Department  string    `json:"department"`
+	Enabled     bool      `json:"enabled"`
+	Version     int       `json:"version"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+// AppProduct represents a product sent in an event.
+type AppProduct struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	Name        string    `json:"name"`
+	Cost        float64   `json:"cost"`
+	Quantity    int       `json:"quantity"`
+	Version     int       `json:"version"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+// AppReset tells the client that events were missed while it was
+// disconnected, so it must reload the data it's interested in.
+type AppReset struct {
+	Message string `json:"message"`
+}
+
+// =============================================================================
+
+func toAppEvent(evt event.Event) AppEvent {
+	app := AppEvent{
+		Domain:   evt.Domain,
+		Action:   evt.Action,
+		EntityID: evt.EntityID.String(),
+		UserID:   evt.UserID.String(),
+		Date:     evt.Date,
+	}
+
+	switch v := evt.Data.(type) {
+	case user.User:
+		app.Data = toAppUser(v)
+	case product.Product:
+		app.Data = toAppProduct(v)
+	}
+
+	return app
+}
+
+func toAppUser(usr user.User) AppUser {
+	roles := make([]string, len(usr.Roles))
+	for i, role := range usr.Roles {
+		roles[i] = role.Name()
+	}
+
+	return AppUser{
+		ID:          usr.ID.String(),
+		Name:        usr.Name,
+		Email:       usr.Email.Address,
+		Roles:       roles,
+		Department:  usr.Department,
+		Enabled:     usr.Enabled,
+		Version:     usr.Version,
+		DateCreated: usr.DateCreated,
+		DateUpdated: usr.DateUpdated,
+	}
+}
+
+func toAppProduct(prd product.Product) AppProduct {
+	return AppProduct{
+		ID:          prd.ID.String(),
+		UserID:      prd.UserID.String(),
+		Name:        prd.Name,
+		Cost:        prd.Cost,
+		Quantity:    prd.Quantity,
+		Version:     prd.Version,
+		DateCreated: prd.DateCreated,
+		DateUpdated: prd.DateUpdated,
+	}
+}
+
+// toServerEvent converts the event for the stream. The event is named after
+// its domain and action, like "product.updated", so clients can listen for
+// the changes they care about.
+func toServerEvent(evt event.Event) web.ServerEvent {
+	return web.ServerEvent{
+		ID:   strconv.FormatUint(evt.ID, 10),
+		Name: evt.Domain + "." + evt.Action,
+		Data: toAppEvent(evt),
+	}
+}
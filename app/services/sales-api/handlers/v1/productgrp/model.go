@@ -0,0 +1,58 @@
+package productgrp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/sys/validate"
+)
+
+// AppProduct represents a product returned by the endpoints.
+type AppProduct struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	Name        string    `json:"name"`
+	Cost        float64   `json:"cost"`
+	Quantity    int       `json:"quantity"`
+	Version     int       `json:"version"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+func toAppProduct(prd product.Product) AppProduct {
+	return AppProduct{
+		ID:          prd.ID.String(),
+		UserID:      prd.UserID.String(),
+		Name:        prd.Name,
+		Cost:        prd.Cost,
+		Quantity:    prd.Quantity,
+		Version:     prd.Version,
+		DateCreated: prd.DateCreated,
+		DateUpdated: prd.DateUpdated,
+	}
+}
+
+// AppUpdateProduct contains information needed to update a product. Only the
+// fields that are sent are changed.
+type AppUpdateProduct struct {
+	Name     *string  `json:"name"`
+	Cost     *float64 `json:"cost" validate:"omitempty,gte=0"`
+	Quantity *int     `json:"quantity" validate:"omitempty,gte=1"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppUpdateProduct) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+	return nil
+}
+
+func toCoreUpdateProduct(app AppUpdateProduct) product.UpdateProduct {
+	return product.UpdateProduct{
+		Name:     app.Name,
+		Cost:     app.Cost,
+		Quantity: app.Quantity,
+	}
+}
@@ -0,0 +1,73 @@
+// Package productgrp maintains the group of handlers for reading and changing
+// a single product. The version of the product is sent as its entity tag, so
+// clients can revalidate what they read and update it conditionally.
+package productgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/farmani/service/business/core/product"
+	v1 "github.com/farmani/service/business/web/v1"
+	"github.com/farmani/service/business/web/v1/middlewares"
+	"github.com/farmani/service/foundation/web"
+)
+
+// Handlers manages the set of product endpoints. The product is loaded and
+// authorized by the AuthorizeProduct middleware before the handlers run.
+type Handlers struct {
+	Product *product.Core
+}
+
+// New constructs a handlers for route access.
+func New(prdCore *product.Core) *Handlers {
+	return &Handlers{
+		Product: prdCore,
+	}
+}
+
+// QueryByID returns the product with its entity tag, or a 304 when the
+// client already has the current version.
+func (h *Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	prd, err := middlewares.GetProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	etag := web.ETag(prd.Version)
+	if web.NotModified(ctx, w, r, etag) {
+		return nil
+	}
+
+	web.SetETag(w, etag)
+
+	return web.Respond(ctx, w, toAppProduct(prd), http.StatusOK)
+}
+
+// Update modifies the fields of the product that are sent. When If-Match is
+// sent the product must still be at that version.
+func (h *Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	prd, err := middlewares.GetProduct(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := web.CheckIfMatch(r, web.ETag(prd.Version)); err != nil {
+		return err
+	}
+
+	var app AppUpdateProduct
+	if err := v1.Decode(r, &app); err != nil {
+		return err
+	}
+
+	updPrd, err := h.Product.Update(ctx, prd, toCoreUpdateProduct(app))
+	if err != nil {
+		return fmt.Errorf("update: productID[%s]: %w", prd.ID, err)
+	}
+
+	web.SetETag(w, web.ETag(updPrd.Version))
+
+	return web.Respond(ctx, w, toAppProduct(updPrd), http.StatusOK)
+}
@@ -0,0 +1,458 @@
+package rpcgrp
+
+import (
+	"net/mail"
+	"time"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/cview/user/summary"
+	"github.com/farmani/service/business/sys/validate"
+	"github.com/google/uuid"
+)
+
+// AppErrorData is sent as the data of the error objects. Code is the stable
+// code the REST routes send in their problems for the same error.
+type AppErrorData struct {
+	Code     string            `json:"code"`
+	Status   int               `json:"status"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+}
+
+// AppPaging holds the page and the ordering requested from a query method.
+// The ordering is in the form of "field,direction", like "name,DESC".
+type AppPaging struct {
+	Page    int    `json:"page" validate:"omitempty,min=1"`
+	Rows    int    `json:"rows" validate:"omitempty,min=1,max=100"`
+	OrderBy string `json:"orderBy"`
+}
+
+// paging returns the page and the rows per page, with the defaults for the
+// ones that weren't sent.
+func (app AppPaging) paging() (int, int) {
+	page, rows := app.Page, app.Rows
+	if page == 0 {
+		page = defaultPage
+	}
+	if rows == 0 {
+		rows = defaultRows
+	}
+
+	return page, rows
+}
+
+// AppPage represents a page of the results of a query method.
+type AppPage struct {
+	Items       any `json:"items"`
+	Total       int `json:"total"`
+	Page        int `json:"page"`
+	RowsPerPage int `json:"rowsPerPage"`
+}
+
+// AppID identifies the user or product a method acts on. The version is
+// optional, when it's sent the methods changing the entity fail unless it's
+// still the current one.
+type AppID struct {
+	ID      string `json:"id" validate:"required,uuid"`
+	Version *int   `json:"version"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppID) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+// =============================================================================
+
+// AppUser represents a user returned by the methods.
+type AppUser struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Roles       []string  `json:"roles"`
+	Department  string    `json:"department"`
+	Enabled     bool      `json:"enabled"`
+	Version     int       `json:"version"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+func toAppUser(usr user.User) AppUser {
+	roles := make([]string, len(usr.Roles))
+	for i, role := range usr.Roles {
+		roles[i] = role.Name()
+	}
+
+	return AppUser{
+		ID:          usr.ID.String(),
+		Name:        usr.Name,
+		Email:       usr.Email.Address,
+		Roles:       roles,
+		Department:  usr.Department,
+		Enabled:     usr.Enabled,
+		Version:     usr.Version,
+		DateCreated: usr.DateCreated,
+		DateUpdated: usr.DateUpdated,
+	}
+}
+
+// AppUserQuery holds the params of users.query.
+type AppUserQuery struct {
+	AppPaging
+	ID               string     `json:"id" validate:"omitempty,uuid"`
+	Name             string     `json:"name" validate:"omitempty,min=3"`
+	Email            string     `json:"email" validate:"omitempty,email"`
+	StartCreatedDate *time.Time `json:"startCreatedDate"`
+	EndCreatedDate   *time.Time `json:"endCreatedDate"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppUserQuery) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+func toCoreUserFilter(app AppUserQuery) (user.QueryFilter, error) {
+	var filter user.QueryFilter
+
+	if app.ID != "" {
+		userID, err := uuid.Parse(app.ID)
+		if err != nil {
+			return user.QueryFilter{}, validate.NewFieldsError("id", err)
+		}
+		filter.WithUserID(userID)
+	}
+
+	if app.Name != "" {
+		filter.WithName(app.Name)
+	}
+
+	if app.Email != "" {
+		addr, err := mail.ParseAddress(app.Email)
+		if err != nil {
+			return user.QueryFilter{}, validate.NewFieldsError("email", err)
+		}
+		filter.WithEmail(*addr)
+	}
+
+	if app.StartCreatedDate != nil {
+		filter.WithStartDateCreated(*app.StartCreatedDate)
+	}
+
+	if app.EndCreatedDate != nil {
+		filter.WithEndCreatedDate(*app.EndCreatedDate)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return user.QueryFilter{}, err
+	}
+
+	return filter, nil
+}
+
+// AppNewUser holds the params of users.create.
+type AppNewUser struct {
+	Name            string   `json:"name" validate:"required"`
+	Email           string   `json:"email" validate:"required,email"`
+	Roles           []string `json:"roles" validate:"required"`
+	Department      string   `json:"department"`
+	Password        string   `json:"password" validate:"required"`
+	PasswordConfirm string   `json:"passwordConfirm" validate:"eqfield=Password"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppNewUser) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+func toCoreNewUser(app AppNewUser) (user.CreateUser, error) {
+	roles, err := parseRoles(app.Roles)
+	if err != nil {
+		return user.CreateUser{}, err
+	}
+
+	addr, err := mail.ParseAddress(app.Email)
+	if err != nil {
+		return user.CreateUser{}, validate.NewFieldsError("email", err)
+	}
+
+	cu := user.CreateUser{
+		Name:            app.Name,
+		Email:           *addr,
+		Roles:           roles,
+		Department:      app.Department,
+		Password:        app.Password,
+		PasswordConfirm: app.PasswordConfirm,
+	}
+
+	return cu, nil
+}
+
+// AppUpdateUser holds the params of users.update. Only the fields that are
+// sent are changed.
+type AppUpdateUser struct {
+	ID              string   `json:"id" validate:"required,uuid"`
+	Version         *int     `json:"version"`
+	Name            *string  `json:"name"`
+	Email           *string  `json:"email" validate:"omitempty,email"`
+	Roles           []string `json:"roles"`
+	Department      *string  `json:"department"`
+	Password        *string  `json:"password"`
+	PasswordConfirm *string  `json:"passwordConfirm" validate:"omitempty,eqfield=Password"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppUpdateUser) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+func toCoreUpdateUser(app AppUpdateUser) (user.UpdateUser, error) {
+	var roles []user.Role
+	if app.Roles != nil {
+		var err error
+		roles, err = parseRoles(app.Roles)
+		if err != nil {
+			return user.UpdateUser{}, err
+		}
+	}
+
+	var addr *mail.Address
+	if app.Email != nil {
+		var err error
+		addr, err = mail.ParseAddress(*app.Email)
+		if err != nil {
+			return user.UpdateUser{}, validate.NewFieldsError("email", err)
+		}
+	}
+
+	uu := user.UpdateUser{
+		Name:            app.Name,
+		Email:           addr,
+		Roles:           roles,
+		Department:      app.Department,
+		Password:        app.Password,
+		PasswordConfirm: app.PasswordConfirm,
+		Enabled:         app.Enabled,
+	}
+
+	return uu, nil
+}
+
+func parseRoles(values []string) ([]user.Role, error) {
+	roles := make([]user.Role, len(values))
+	for i, value := range values {
+		role, err := user.ParseRole(value)
+		if err != nil {
+			return nil, validate.NewFieldsError("roles", err)
+		}
+		roles[i] = role
+	}
+
+	return roles, nil
+}
+
+// =============================================================================
+
+// AppProduct represents a product returned by the methods.
+type AppProduct struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"userId"`
+	Name        string    `json:"name"`
+	Cost        float64   `json:"cost"`
+	Quantity    int       `json:"quantity"`
+	Version     int       `json:"version"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+func toAppProduct(prd product.Product) AppProduct {
+	return AppProduct{
+		ID:          prd.ID.String(),
+		UserID:      prd.UserID.String(),
+		Name:        prd.Name,
+		Cost:        prd.Cost,
+		Quantity:    prd.Quantity,
+		Version:     prd.Version,
+		DateCreated: prd.DateCreated,
+		DateUpdated: prd.DateUpdated,
+	}
+}
+
+// AppProductQuery holds the params of products.query.
+type AppProductQuery struct {
+	AppPaging
+	ID       string   `json:"id" validate:"omitempty,uuid"`
+	Name     string   `json:"name" validate:"omitempty,min=3"`
+	Cost     *float64 `json:"cost"`
+	Quantity *int     `json:"quantity"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppProductQuery) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+func toCoreProductFilter(app AppProductQuery) (product.QueryFilter, error) {
+	var filter product.QueryFilter
+
+	if app.ID != "" {
+		productID, err := uuid.Parse(app.ID)
+		if err != nil {
+			return product.QueryFilter{}, validate.NewFieldsError("id", err)
+		}
+		filter.WithProductID(productID)
+	}
+
+	if app.Name != "" {
+		filter.WithName(app.Name)
+	}
+
+	if app.Cost != nil {
+		filter.WithCost(*app.Cost)
+	}
+
+	if app.Quantity != nil {
+		filter.WithQuantity(*app.Quantity)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return product.QueryFilter{}, err
+	}
+
+	return filter, nil
+}
+
+// AppNewProduct holds the params of products.create. The product belongs to
+// the caller when no user is sent.
+type AppNewProduct struct {
+	UserID   string  `json:"userId" validate:"omitempty,uuid"`
+	Name     string  `json:"name" validate:"required"`
+	Cost     float64 `json:"cost" validate:"gte=0"`
+	Quantity int     `json:"quantity" validate:"gte=1"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppNewProduct) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+func toCoreNewProduct(app AppNewProduct) (product.NewProduct, error) {
+	var userID uuid.UUID
+	if app.UserID != "" {
+		var err error
+		userID, err = uuid.Parse(app.UserID)
+		if err != nil {
+			return product.NewProduct{}, validate.NewFieldsError("userId", err)
+		}
+	}
+
+	np := product.NewProduct{
+		UserID:   userID,
+		Name:     app.Name,
+		Cost:     app.Cost,
+		Quantity: app.Quantity,
+	}
+
+	return np, nil
+}
+
+// AppUpdateProduct holds the params of products.update. Only the fields that
+// are sent are changed.
+type AppUpdateProduct struct {
+	ID       string   `json:"id" validate:"required,uuid"`
+	Version  *int     `json:"version"`
+	Name     *string  `json:"name"`
+	Cost     *float64 `json:"cost" validate:"omitempty,gte=0"`
+	Quantity *int     `json:"quantity" validate:"omitempty,gte=1"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppUpdateProduct) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+func toCoreUpdateProduct(app AppUpdateProduct) product.UpdateProduct {
+	return product.UpdateProduct{
+		Name:     app.Name,
+		Cost:     app.Cost,
+		Quantity: app.Quantity,
+	}
+}
+
+// =============================================================================
+
+// AppSummary represents the summary of a user and their products.
+type AppSummary struct {
+	UserID     string  `json:"userId"`
+	UserName   string  `json:"userName"`
+	TotalCount int     `json:"totalCount"`
+	TotalCost  float64 `json:"totalCost"`
+}
+
+func toAppSummary(smm summary.Summary) AppSummary {
+	return AppSummary{
+		UserID:     smm.UserID.String(),
+		UserName:   smm.UserName,
+		TotalCount: smm.TotalCount,
+		TotalCost:  smm.TotalCost,
+	}
+}
+
+// AppSummaryQuery holds the params of summary.query.
+type AppSummaryQuery struct {
+	AppPaging
+	UserID   string `json:"userId" validate:"omitempty,uuid"`
+	UserName string `json:"userName" validate:"omitempty,min=3"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppSummaryQuery) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return err
+	}
+	return nil
+}
+
+func toCoreSummaryFilter(app AppSummaryQuery) (summary.QueryFilter, error) {
+	var filter summary.QueryFilter
+
+	if app.UserID != "" {
+		userID, err := uuid.Parse(app.UserID)
+		if err != nil {
+			return summary.QueryFilter{}, validate.NewFieldsError("userId", err)
+		}
+		filter.WithUserID(userID)
+	}
+
+	if app.UserName != "" {
+		filter.WithUserName(app.UserName)
+	}
+
+	if err := filter.Validate(); err != nil {
+		return summary.QueryFilter{}, err
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,189 @@
+package rpcgrp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/web/auth"
+	"github.com/google/uuid"
+)
+
+// productOrderFields maps the fields products can be ordered by to the core.
+var productOrderFields = map[string]string{
+	"id":       product.OrderByID,
+	"userId":   product.OrderByUserID,
+	"name":     product.OrderByName,
+	"cost":     product.OrderByCost,
+	"quantity": product.OrderByQuantity,
+}
+
+// queryProducts returns a page of the products the caller can list.
+func (h *Handlers) queryProducts(ctx context.Context, params json.RawMessage) (any, error) {
+	var app AppProductQuery
+	if err := decode(params, &app); err != nil {
+		return nil, err
+	}
+
+	filter, err := toCoreProductFilter(app)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := parseOrder(app.OrderBy, productOrderFields, product.DefaultOrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := h.queryFilter(ctx, auth.RuleListAdminOrSubject)
+	if err != nil {
+		return nil, err
+	}
+	filter.WithAccess(access)
+
+	page, rows := app.paging()
+
+	prds, err := h.product.Query(ctx, filter, orderBy, page, rows)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	total, err := h.product.Count(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("count: %w", err)
+	}
+
+	items := make([]AppProduct, len(prds))
+	for i, prd := range prds {
+		items[i] = toAppProduct(prd)
+	}
+
+	return AppPage{Items: items, Total: total, Page: page, RowsPerPage: rows}, nil
+}
+
+// queryProductByID returns the product when the caller is an admin or owns
+// it.
+func (h *Handlers) queryProductByID(ctx context.Context, params json.RawMessage) (any, error) {
+	prd, _, err := h.loadProduct(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAppProduct(prd), nil
+}
+
+// createProduct adds a new product. The product belongs to the caller unless
+// another user is specified, which only admins can do.
+func (h *Handlers) createProduct(ctx context.Context, params json.RawMessage) (any, error) {
+	var app AppNewProduct
+	if err := decode(params, &app); err != nil {
+		return nil, err
+	}
+
+	np, err := toCoreNewProduct(app)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.UserID == "" {
+		subject := auth.GetClaims(ctx).Subject
+
+		np.UserID, err = uuid.Parse(subject)
+		if err != nil {
+			return nil, auth.NewAuthErrorKind(auth.KindForbidden, "authorize: subject[%s]: %s", subject, ErrInvalidSubject)
+		}
+	}
+
+	if err := h.authorize(ctx, np.UserID, auth.RuleAdminOrSubject); err != nil {
+		return nil, err
+	}
+
+	prd, err := h.product.Create(ctx, np)
+	if err != nil {
+		return nil, fmt.Errorf("create: userID[%s]: %w", np.UserID, err)
+	}
+
+	return toAppProduct(prd), nil
+}
+
+// updateProduct modifies the product when the caller is an admin or owns it.
+func (h *Handlers) updateProduct(ctx context.Context, params json.RawMessage) (any, error) {
+	var app AppUpdateProduct
+	if err := decode(params, &app); err != nil {
+		return nil, err
+	}
+
+	prd, err := h.queryProduct(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkVersion(app.Version, prd.Version); err != nil {
+		return nil, err
+	}
+
+	prd, err = h.product.Update(ctx, prd, toCoreUpdateProduct(app))
+	if err != nil {
+		return nil, fmt.Errorf("update: productID[%s]: %w", app.ID, err)
+	}
+
+	return toAppProduct(prd), nil
+}
+
+// deleteProduct removes the product when the caller is an admin or owns it.
+func (h *Handlers) deleteProduct(ctx context.Context, params json.RawMessage) (any, error) {
+	prd, version, err := h.loadProduct(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkVersion(version, prd.Version); err != nil {
+		return nil, err
+	}
+
+	if err := h.product.Delete(ctx, prd); err != nil {
+		return nil, fmt.Errorf("delete: productID[%s]: %w", prd.ID, err)
+	}
+
+	return nil, nil
+}
+
+// =============================================================================
+
+// loadProduct decodes the ID params and loads the product, when the caller
+// is an admin or owns it. The version the client read is returned when it
+// was sent.
+func (h *Handlers) loadProduct(ctx context.Context, params json.RawMessage) (product.Product, *int, error) {
+	var app AppID
+	if err := decode(params, &app); err != nil {
+		return product.Product{}, nil, err
+	}
+
+	prd, err := h.queryProduct(ctx, app.ID)
+	if err != nil {
+		return product.Product{}, nil, err
+	}
+
+	return prd, app.Version, nil
+}
+
+// queryProduct loads the product and checks the caller is an admin or owns
+// it.
+func (h *Handlers) queryProduct(ctx context.Context, id string) (product.Product, error) {
+	productID, err := uuid.Parse(id)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("parse: id[%s]: %w", id, err)
+	}
+
+	prd, err := h.product.QueryByID(ctx, productID)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("querybyid: productID[%s]: %w", productID, err)
+	}
+
+	if err := h.authorize(ctx, prd.UserID, auth.RuleAdminOrSubject); err != nil {
+		return product.Product{}, err
+	}
+
+	return prd, nil
+}
@@ -0,0 +1,271 @@
+// Package rpcgrp maintains the group of handlers serving the core APIs over
+// JSON-RPC 2.0.
+package rpcgrp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/cview/user/summary"
+	"github.com/farmani/service/business/data/access"
+	"github.com/farmani/service/business/data/order"
+	"github.com/farmani/service/business/sys/validate"
+	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/v1/middlewares"
+	"github.com/farmani/service/foundation/jsonrpc"
+	"github.com/farmani/service/foundation/web"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// contentType is the media type of the responses.
+const contentType = "application/json"
+
+// Set of defaults for the query methods.
+const (
+	defaultPage = 1
+	defaultRows = 10
+)
+
+// ErrInvalidSubject is returned when the caller acts on its own products but
+// isn't a user, like an internal service.
+var ErrInvalidSubject = errors.New("subject is not a user")
+
+// Config contains the systems the methods are served with. The methods of a
+// core are only registered when the core is provided.
+type Config struct {
+	Log         *zap.SugaredLogger
+	Auth        *auth.Auth
+	UserCore    *user.Core
+	ProductCore *product.Core
+	SummaryCore *summary.Core
+}
+
+// Handlers manages the set of JSON-RPC methods.
+type Handlers struct {
+	log     *zap.SugaredLogger
+	auth    *auth.Auth
+	user    *user.Core
+	product *product.Core
+	summary *summary.Core
+	server  *jsonrpc.Server
+}
+
+// New constructs a handlers for route access with the methods of the
+// provided cores registered.
+func New(cfg Config) *Handlers {
+	h := Handlers{
+		log:     cfg.Log,
+		auth:    cfg.Auth,
+		user:    cfg.UserCore,
+		product: cfg.ProductCore,
+		summary: cfg.SummaryCore,
+		server:  jsonrpc.NewServer(jsonrpc.Config{}),
+	}
+
+	if h.user != nil {
+		h.register("users.query", h.queryUsers)
+		h.register("users.queryByID", h.queryUserByID)
+		h.register("users.create", h.createUser)
+		h.register("users.update", h.updateUser)
+		h.register("users.delete", h.deleteUser)
+	}
+
+	if h.product != nil {
+		h.register("products.query", h.queryProducts)
+		h.register("products.queryByID", h.queryProductByID)
+		h.register("products.create", h.createProduct)
+		h.register("products.update", h.updateProduct)
+		h.register("products.delete", h.deleteProduct)
+	}
+
+	if h.summary != nil {
+		h.register("summary.query", h.querySummary)
+	}
+
+	return &h
+}
+
+// Serve executes the call or batch of calls in the body of the request. The
+// request has been authenticated already, each method authorizes the call
+// against the claims itself. The outcome of the calls is sent with a 200,
+// even when they failed, while a body of notifications only gets a 204.
+func (h *Handlers) Serve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var msg json.RawMessage
+	if err := web.Decode(r, &msg); err != nil {
+		de := web.GetDecodeError(err)
+		if de == nil || de.Status != http.StatusBadRequest {
+			return err
+		}
+
+		resp := jsonrpc.ErrorResponse(nil, jsonrpc.NewError(jsonrpc.CodeParseError, "parse error: %s", de.Err))
+		return web.RespondJSON(ctx, w, resp, http.StatusOK, contentType)
+	}
+
+	resp, ok := h.server.Serve(ctx, msg)
+	if !ok {
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	return web.RespondJSON(ctx, w, resp, http.StatusOK, contentType)
+}
+
+// register adds the method to the server. The errors of the method are
+// logged, since they don't reach the error middleware, and converted into
+// error objects.
+func (h *Handlers) register(method string, fn jsonrpc.Handler) {
+	f := func(ctx context.Context, params json.RawMessage) (any, error) {
+		ctx, span := web.AddSpan(ctx, "app.rpcgrp.call", attribute.String("method", method))
+		defer span.End()
+
+		result, err := fn(ctx, params)
+		if err != nil {
+			h.log.Errorw("error", "trace_id", web.GetTraceID(ctx), "method", method, "message", err)
+			return nil, toRPCError(ctx, err)
+		}
+
+		return result, nil
+	}
+
+	h.server.Register(method, f)
+}
+
+// =============================================================================
+
+// authorize executes the rule against the resource owned by the user, the
+// same way the Authorize middlewares do for the REST routes.
+func (h *Handlers) authorize(ctx context.Context, userID uuid.UUID, rule string) error {
+	claims := auth.GetClaims(ctx)
+	if claims.Subject == "" {
+		return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+	}
+
+	if err := h.auth.Authorize(ctx, claims, userID, rule); err != nil {
+		return auth.NewAuthErrorKind(auth.KindForbidden, "authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
+	}
+
+	return nil
+}
+
+// authorizeRoles executes the rule against the roles of the caller alone, the
+// same way the Authorize middleware does for the REST routes.
+func (h *Handlers) authorizeRoles(ctx context.Context, rule string) error {
+	claims := auth.GetClaims(ctx)
+	if claims.Subject == "" {
+		return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+	}
+
+	if err := h.auth.AuthorizeRoles(ctx, claims, rule); err != nil {
+		return auth.NewAuthErrorKind(auth.KindForbidden, "authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
+	}
+
+	return nil
+}
+
+// queryFilter produces the access filter for the list rule, the same way the
+// AuthorizeQuery middleware does for the REST routes.
+func (h *Handlers) queryFilter(ctx context.Context, rule string) (access.Filter, error) {
+	claims := auth.GetClaims(ctx)
+	if claims.Subject == "" {
+		return access.Filter{}, auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+	}
+
+	filter, err := h.auth.QueryFilter(ctx, claims, rule)
+	if err != nil {
+		return access.Filter{}, fmt.Errorf("queryfilter: rule[%v]: %w", rule, err)
+	}
+
+	return filter, nil
+}
+
+// decode reads the params into the value and then validates it when the
+// value has a Validate method. Missing params are decoded as an empty
+// object, so the validation reports the required fields.
+func decode(params json.RawMessage, val any) error {
+	if params == nil {
+		params = json.RawMessage("{}")
+	}
+
+	d := json.NewDecoder(bytes.NewReader(params))
+	d.DisallowUnknownFields()
+
+	if err := d.Decode(val); err != nil {
+		return validate.NewFieldsError("params", err)
+	}
+
+	if v, ok := val.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseOrder parses the ordering in the form of "field,direction", where
+// field is one of the names the method accepts.
+func parseOrder(v string, fields map[string]string, defaultOrder order.By) (order.By, error) {
+	if v == "" {
+		return defaultOrder, nil
+	}
+
+	by, err := order.ParseValue(v, defaultOrder)
+	if err != nil {
+		return order.By{}, err
+	}
+
+	field, exists := fields[by.Field]
+	if !exists {
+		return order.By{}, validate.NewFieldsError("orderBy", fmt.Errorf("unknown order field %q", by.Field))
+	}
+
+	return order.NewBy(field, by.Direction), nil
+}
+
+// checkVersion compares the version the client read with the current one,
+// when the client sent it.
+func checkVersion(version *int, current int) error {
+	if version != nil && *version != current {
+		return web.ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// toRPCError converts the error into the error object sent to the client.
+// The message and the data come from the problem the REST routes would send
+// for the error. Failed validations are invalid params, other client errors
+// use the server error range offset by the status, so a 404 is -32004.
+func toRPCError(ctx context.Context, err error) *jsonrpc.Error {
+	prob := middlewares.ErrorProblem(err)
+
+	code := jsonrpc.CodeInternalError
+	switch {
+	case prob.Status == http.StatusBadRequest:
+		code = jsonrpc.CodeInvalidParams
+	case prob.Status > http.StatusBadRequest && prob.Status < http.StatusInternalServerError:
+		code = jsonrpc.CodeServerError - (prob.Status - http.StatusBadRequest)
+	case prob.Status > http.StatusInternalServerError:
+		code = jsonrpc.CodeServerError
+	}
+
+	rpcErr := jsonrpc.Error{
+		Code:    code,
+		Message: prob.Detail,
+		Data: AppErrorData{
+			Code:     prob.Code,
+			Status:   prob.Status,
+			Fields:   prob.Fields,
+			Instance: web.GetTraceID(ctx),
+		},
+	}
+
+	return &rpcErr
+}
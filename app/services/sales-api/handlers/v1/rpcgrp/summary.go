@@ -0,0 +1,58 @@
+package rpcgrp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/farmani/service/business/cview/user/summary"
+	"github.com/farmani/service/business/web/auth"
+)
+
+// summaryOrderFields maps the fields summaries can be ordered by to the core.
+var summaryOrderFields = map[string]string{
+	"userId":   summary.OrderByUserID,
+	"userName": summary.OrderByUserName,
+}
+
+// querySummary returns a page of the summaries of the users and their
+// products, only admins can.
+func (h *Handlers) querySummary(ctx context.Context, params json.RawMessage) (any, error) {
+	var app AppSummaryQuery
+	if err := decode(params, &app); err != nil {
+		return nil, err
+	}
+
+	filter, err := toCoreSummaryFilter(app)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := parseOrder(app.OrderBy, summaryOrderFields, summary.DefaultOrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.authorizeRoles(ctx, auth.RuleAdminOnly); err != nil {
+		return nil, err
+	}
+
+	page, rows := app.paging()
+
+	smms, err := h.summary.Query(ctx, filter, orderBy, page, rows)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	total, err := h.summary.Count(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("count: %w", err)
+	}
+
+	items := make([]AppSummary, len(smms))
+	for i, smm := range smms {
+		items[i] = toAppSummary(smm)
+	}
+
+	return AppPage{Items: items, Total: total, Page: page, RowsPerPage: rows}, nil
+}
@@ -0,0 +1,189 @@
+package rpcgrp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/web/auth"
+	"github.com/google/uuid"
+)
+
+// userOrderFields maps the fields users can be ordered by to the core.
+var userOrderFields = map[string]string{
+	"id":      user.OrderByID,
+	"name":    user.OrderByName,
+	"email":   user.OrderByEmail,
+	"roles":   user.OrderByRoles,
+	"enabled": user.OrderByEnabled,
+}
+
+// queryUsers returns a page of the users the caller can list.
+func (h *Handlers) queryUsers(ctx context.Context, params json.RawMessage) (any, error) {
+	var app AppUserQuery
+	if err := decode(params, &app); err != nil {
+		return nil, err
+	}
+
+	filter, err := toCoreUserFilter(app)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := parseOrder(app.OrderBy, userOrderFields, user.DefaultOrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := h.queryFilter(ctx, auth.RuleListAdminOrSubject)
+	if err != nil {
+		return nil, err
+	}
+	filter.WithAccess(access)
+
+	page, rows := app.paging()
+
+	users, err := h.user.Query(ctx, filter, orderBy, page, rows)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+
+	total, err := h.user.Count(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("count: %w", err)
+	}
+
+	items := make([]AppUser, len(users))
+	for i, usr := range users {
+		items[i] = toAppUser(usr)
+	}
+
+	return AppPage{Items: items, Total: total, Page: page, RowsPerPage: rows}, nil
+}
+
+// queryUserByID returns the user when the caller is an admin or the user.
+func (h *Handlers) queryUserByID(ctx context.Context, params json.RawMessage) (any, error) {
+	usr, _, err := h.loadUser(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return toAppUser(usr), nil
+}
+
+// createUser adds a new user, only admins can.
+func (h *Handlers) createUser(ctx context.Context, params json.RawMessage) (any, error) {
+	var app AppNewUser
+	if err := decode(params, &app); err != nil {
+		return nil, err
+	}
+
+	nu, err := toCoreNewUser(app)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.authorizeRoles(ctx, auth.RuleAdminOnly); err != nil {
+		return nil, err
+	}
+
+	usr, err := h.user.Create(ctx, nu)
+	if err != nil {
+		return nil, fmt.Errorf("create: email[%s]: %w", nu.Email.Address, err)
+	}
+
+	return toAppUser(usr), nil
+}
+
+// updateUser modifies the user when the caller is an admin or the user. Only
+// admins can change the roles of a user or disable it.
+func (h *Handlers) updateUser(ctx context.Context, params json.RawMessage) (any, error) {
+	var app AppUpdateUser
+	if err := decode(params, &app); err != nil {
+		return nil, err
+	}
+
+	uu, err := toCoreUpdateUser(app)
+	if err != nil {
+		return nil, err
+	}
+
+	usr, err := h.queryUser(ctx, app.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if uu.Roles != nil || uu.Enabled != nil {
+		if err := h.authorizeRoles(ctx, auth.RuleAdminOnly); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkVersion(app.Version, usr.Version); err != nil {
+		return nil, err
+	}
+
+	usr, err = h.user.Update(ctx, usr, uu)
+	if err != nil {
+		return nil, fmt.Errorf("update: userID[%s]: %w", app.ID, err)
+	}
+
+	return toAppUser(usr), nil
+}
+
+// deleteUser removes the user when the caller is an admin or the user.
+func (h *Handlers) deleteUser(ctx context.Context, params json.RawMessage) (any, error) {
+	usr, version, err := h.loadUser(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkVersion(version, usr.Version); err != nil {
+		return nil, err
+	}
+
+	if err := h.user.Delete(ctx, usr); err != nil {
+		return nil, fmt.Errorf("delete: userID[%s]: %w", usr.ID, err)
+	}
+
+	return nil, nil
+}
+
+// =============================================================================
+
+// loadUser decodes the ID params and loads the user, when the caller is an
+// admin or the user. The version the client read is returned when it was
+// sent.
+func (h *Handlers) loadUser(ctx context.Context, params json.RawMessage) (user.User, *int, error) {
+	var app AppID
+	if err := decode(params, &app); err != nil {
+		return user.User{}, nil, err
+	}
+
+	usr, err := h.queryUser(ctx, app.ID)
+	if err != nil {
+		return user.User{}, nil, err
+	}
+
+	return usr, app.Version, nil
+}
+
+// queryUser loads the user and checks the caller is an admin or the user.
+func (h *Handlers) queryUser(ctx context.Context, id string) (user.User, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return user.User{}, fmt.Errorf("parse: id[%s]: %w", id, err)
+	}
+
+	usr, err := h.user.QueryByID(ctx, userID)
+	if err != nil {
+		return user.User{}, fmt.Errorf("querybyid: userID[%s]: %w", userID, err)
+	}
+
+	if err := h.authorize(ctx, usr.ID, auth.RuleAdminOrSubject); err != nil {
+		return user.User{}, err
+	}
+
+	return usr, nil
+}
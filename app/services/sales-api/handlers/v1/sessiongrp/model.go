@@ -0,0 +1,10 @@
+package sessiongrp
+
+import "time"
+
+// AppSession represents a new browser session returned to the client. The
+// session token itself is only sent as a cookie.
+type AppSession struct {
+	CSRFToken   string    `json:"csrfToken"`
+	DateExpires time.Time `json:"dateExpires"`
+}
@@ -0,0 +1,67 @@
+// Package sessiongrp maintains the group of handlers for browser sessions.
+package sessiongrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/session"
+	"github.com/farmani/service/foundation/web"
+)
+
+// Handlers manages the set of session endpoints.
+type Handlers struct {
+	Sessions *session.Manager
+}
+
+// New constructs a handlers for route access.
+func New(sessions *session.Manager) *Handlers {
+	return &Handlers{
+		Sessions: sessions,
+	}
+}
+
+// Create exchanges the claims of an authenticated request for a browser
+// session. The session and csrf cookies are set on the response.
+func (h *Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims := auth.GetClaims(ctx)
+
+	roles := make([]string, len(claims.Roles))
+	for i, role := range claims.Roles {
+		roles[i] = role.Name()
+	}
+
+	ns := session.NewSession{
+		Subject: claims.Subject,
+		Roles:   roles,
+	}
+
+	sess, token, err := h.Sessions.Create(ctx, ns, web.GetTime(ctx))
+	if err != nil {
+		return fmt.Errorf("create: subject[%s]: %w", claims.Subject, err)
+	}
+
+	h.Sessions.SetCookies(w, sess, token)
+
+	resp := AppSession{
+		CSRFToken:   sess.CSRFToken,
+		DateExpires: sess.DateExpires,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+// Delete ends the browser session and clears the cookies.
+func (h *Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if token, err := session.Token(r); err == nil {
+		if err := h.Sessions.Delete(ctx, token); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+	}
+
+	h.Sessions.ClearCookies(w)
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package usergrp
+
+import (
+	"fmt"
+	"net/mail"
+	"time"
+
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/sys/validate"
+)
+
+// AppUser represents a user returned by the endpoints.
+type AppUser struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Roles       []string  `json:"roles"`
+	Department  string    `json:"department"`
+	Enabled     bool      `json:"enabled"`
+	Version     int       `json:"version"`
+	DateCreated time.Time `json:"dateCreated"`
+	DateUpdated time.Time `json:"dateUpdated"`
+}
+
+func toAppUser(usr user.User) AppUser {
+	roles := make([]string, len(usr.Roles))
+	for i, role := range usr.Roles {
+		roles[i] = role.Name()
+	}
+
+	return AppUser{
+		ID:          usr.ID.String(),
+		Name:        usr.Name,
+		Email:       usr.Email.Address,
+		Roles:       roles,
+		Department:  usr.Department,
+		Enabled:     usr.Enabled,
+		Version:     usr.Version,
+		DateCreated: usr.DateCreated,
+		DateUpdated: usr.DateUpdated,
+	}
+}
+
+// AppUpdateUser contains information needed to update a user. Only the fields
+// that are sent are changed.
+type AppUpdateUser struct {
+	Name            *string  `json:"name"`
+	Email           *string  `json:"email" validate:"omitempty,email"`
+	Roles           []string `json:"roles"`
+	Department      *string  `json:"department"`
+	Password        *string  `json:"password"`
+	PasswordConfirm *string  `json:"passwordConfirm" validate:"omitempty,eqfield=Password"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+// Validate checks the data in the model is considered clean.
+func (app AppUpdateUser) Validate() error {
+	if err := validate.Check(app); err != nil {
+		return fmt.Errorf("validate: %w", err)
+	}
+	return nil
+}
+
+func toCoreUpdateUser(app AppUpdateUser) (user.UpdateUser, error) {
+	var roles []user.Role
+	if app.Roles != nil {
+		roles = make([]user.Role, len(app.Roles))
+		for i, value := range app.Roles {
+			role, err := user.ParseRole(value)
+			if err != nil {
+				return user.UpdateUser{}, validate.NewFieldsError("roles", err)
+			}
+			roles[i] = role
+		}
+	}
+
+	var addr *mail.Address
+	if app.Email != nil {
+		var err error
+		addr, err = mail.ParseAddress(*app.Email)
+		if err != nil {
+			return user.UpdateUser{}, validate.NewFieldsError("email", err)
+		}
+	}
+
+	uu := user.UpdateUser{
+		Name:            app.Name,
+		Email:           addr,
+		Roles:           roles,
+		Department:      app.Department,
+		Password:        app.Password,
+		PasswordConfirm: app.PasswordConfirm,
+		Enabled:         app.Enabled,
+	}
+
+	return uu, nil
+}
@@ -0,0 +1,89 @@
+// Package usergrp maintains the group of handlers for reading and changing a
+// single user. The version of the user is sent as its entity tag, so clients
+// can revalidate what they read and update it conditionally.
+package usergrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/web/auth"
+	v1 "github.com/farmani/service/business/web/v1"
+	"github.com/farmani/service/business/web/v1/middlewares"
+	"github.com/farmani/service/foundation/web"
+)
+
+// Handlers manages the set of user endpoints. The user is loaded and
+// authorized by the AuthorizeUser middleware before the handlers run.
+type Handlers struct {
+	User *user.Core
+	Auth *auth.Auth
+}
+
+// New constructs a handlers for route access.
+func New(usrCore *user.Core, a *auth.Auth) *Handlers {
+	return &Handlers{
+		User: usrCore,
+		Auth: a,
+	}
+}
+
+// QueryByID returns the user with its entity tag, or a 304 when the client
+// already has the current version.
+func (h *Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := middlewares.GetUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	etag := web.ETag(usr.Version)
+	if web.NotModified(ctx, w, r, etag) {
+		return nil
+	}
+
+	web.SetETag(w, etag)
+
+	return web.Respond(ctx, w, toAppUser(usr), http.StatusOK)
+}
+
+// Update modifies the fields of the user that are sent. When If-Match is sent
+// the user must still be at that version. Only admins can change the roles of
+// a user or disable it.
+func (h *Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	usr, err := middlewares.GetUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := web.CheckIfMatch(r, web.ETag(usr.Version)); err != nil {
+		return err
+	}
+
+	var app AppUpdateUser
+	if err := v1.Decode(r, &app); err != nil {
+		return err
+	}
+
+	uu, err := toCoreUpdateUser(app)
+	if err != nil {
+		return err
+	}
+
+	if uu.Roles != nil || uu.Enabled != nil {
+		claims := auth.GetClaims(ctx)
+		if err := h.Auth.AuthorizeRoles(ctx, claims, auth.RuleAdminOnly); err != nil {
+			return auth.NewAuthErrorKind(auth.KindForbidden, "authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, auth.RuleAdminOnly, err)
+		}
+	}
+
+	updUsr, err := h.User.Update(ctx, usr, uu)
+	if err != nil {
+		return fmt.Errorf("update: userID[%s]: %w", usr.ID, err)
+	}
+
+	web.SetETag(w, web.ETag(updUsr.Version))
+
+	return web.Respond(ctx, w, toAppUser(updUsr), http.StatusOK)
+}
@@ -0,0 +1,165 @@
+// Package v1 contains the full set of handler functions and routes
+// supported by the v1 web api.
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/farmani/service/app/services/sales-api/handlers/v1/docgrp"
+	"github.com/farmani/service/app/services/sales-api/handlers/v1/eventgrp"
+	"github.com/farmani/service/app/services/sales-api/handlers/v1/productgrp"
+	"github.com/farmani/service/app/services/sales-api/handlers/v1/rpcgrp"
+	"github.com/farmani/service/app/services/sales-api/handlers/v1/sessiongrp"
+	"github.com/farmani/service/app/services/sales-api/handlers/v1/usergrp"
+	"github.com/farmani/service/business/core/event"
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/cview/user/summary"
+	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/idempotency"
+	"github.com/farmani/service/business/web/ratelimit"
+	"github.com/farmani/service/business/web/session"
+	"github.com/farmani/service/business/web/v1/middlewares"
+	"github.com/farmani/service/foundation/jsonrpc"
+	"github.com/farmani/service/foundation/web"
+	"go.uber.org/zap"
+)
+
+// Config contains all the mandatory systems required by handlers.
+type Config struct {
+	Build       string
+	Log         *zap.SugaredLogger
+	Auth        *auth.Auth
+	Sessions    *session.Manager
+	RateLimiter *ratelimit.Limiter
+	Idempotency *idempotency.Manager
+	Events      *event.Broadcaster
+	Heartbeat   time.Duration
+	UserCore    *user.Core
+	ProductCore *product.Core
+	SummaryCore *summary.Core
+}
+
+// Routes binds all the version 1 routes under the /v1 prefix. Once a newer
+// version is mounted beside it, the group can be marked with Deprecate.
+func Routes(app *web.App, cfg Config) {
+	const version = "/v1"
+
+	g := app.Group(version)
+
+	// The rate limiter follows authentication so authenticated clients are
+	// limited by subject rather than by ip address.
+	limit := middlewares.RateLimit(cfg.RateLimiter)
+	idem := middlewares.Idempotency(cfg.Log, cfg.Idempotency)
+
+	// Browser clients can use a session cookie in place of a bearer token
+	// when session support is configured.
+	authen := middlewares.Authenticate(cfg.Auth)
+	if cfg.Sessions != nil {
+		authen = middlewares.AuthenticateSession(cfg.Auth, cfg.Sessions)
+	}
+
+	dgh := docgrp.New(cfg.Build, app)
+	g.Handle(http.MethodGet, "/openapi.json", dgh.OpenAPI, limit)
+
+	if cfg.Sessions != nil {
+		sgh := sessiongrp.New(cfg.Sessions)
+		g.Handle(http.MethodPost, "/sessions", sgh.Create, middlewares.Authenticate(cfg.Auth), limit, middlewares.Authorize(cfg.Auth, auth.RuleAny)).
+			Describe(web.EndpointDoc{
+				Summary:     "Create a browser session",
+				Description: "Exchanges a bearer token for the session and csrf cookies.",
+				Tags:        []string{"sessions"},
+				Response:    sessiongrp.AppSession{},
+				Status:      http.StatusCreated,
+				Auth:        auth.RuleAny,
+			})
+		g.Handle(http.MethodDelete, "/sessions", sgh.Delete, middlewares.AuthenticateSession(cfg.Auth, cfg.Sessions), limit).
+			Describe(web.EndpointDoc{
+				Summary: "Delete the browser session",
+				Tags:    []string{"sessions"},
+				Status:  http.StatusNoContent,
+				Auth:    auth.RuleAuthenticate,
+			})
+	}
+
+	if cfg.Events != nil {
+		egh := eventgrp.New(cfg.Events, cfg.Heartbeat)
+		g.Handle(http.MethodGet, "/events", egh.Stream, authen, limit, middlewares.AuthorizeQuery(cfg.Auth, auth.RuleListAdminOrSubject)).
+			Describe(web.EndpointDoc{
+				Summary:     "Stream user and product changes",
+				Description: "Server-sent events for the users and products the caller can list. Send Last-Event-ID to resume.",
+				Tags:        []string{"events"},
+				Response:    eventgrp.AppEvent{},
+				Status:      http.StatusOK,
+				Auth:        auth.RuleListAdminOrSubject,
+			})
+	}
+
+	if cfg.UserCore != nil {
+		ugh := usergrp.New(cfg.UserCore, cfg.Auth)
+		g.Handle(http.MethodGet, "/users/:user_id", ugh.QueryByID, authen, limit, middlewares.AuthorizeUser(cfg.Auth, cfg.UserCore, auth.RuleAdminOrSubject)).
+			Describe(web.EndpointDoc{
+				Summary:     "Get a user",
+				Description: "The version of the user is sent as the ETag. Send If-None-Match to get a 304 when it didn't change.",
+				Tags:        []string{"users"},
+				Response:    usergrp.AppUser{},
+				Status:      http.StatusOK,
+				Auth:        auth.RuleAdminOrSubject,
+			})
+		g.Handle(http.MethodPatch, "/users/:user_id", ugh.Update, authen, limit, middlewares.AuthorizeUser(cfg.Auth, cfg.UserCore, auth.RuleAdminOrSubject), idem).
+			Describe(web.EndpointDoc{
+				Summary:     "Update a user",
+				Description: "Send the ETag of the user in If-Match to fail with a 412 when it was changed since. Only admins can change the roles or enabled.",
+				Tags:        []string{"users"},
+				Request:     usergrp.AppUpdateUser{},
+				Response:    usergrp.AppUser{},
+				Status:      http.StatusOK,
+				Auth:        auth.RuleAdminOrSubject,
+			})
+	}
+
+	if cfg.ProductCore != nil {
+		pgh := productgrp.New(cfg.ProductCore)
+		g.Handle(http.MethodGet, "/products/:product_id", pgh.QueryByID, authen, limit, middlewares.AuthorizeProduct(cfg.Auth, cfg.ProductCore, auth.RuleAdminOrSubject)).
+			Describe(web.EndpointDoc{
+				Summary:     "Get a product",
+				Description: "The version of the product is sent as the ETag. Send If-None-Match to get a 304 when it didn't change.",
+				Tags:        []string{"products"},
+				Response:    productgrp.AppProduct{},
+				Status:      http.StatusOK,
+				Auth:        auth.RuleAdminOrSubject,
+			})
+		g.Handle(http.MethodPatch, "/products/:product_id", pgh.Update, authen, limit, middlewares.AuthorizeProduct(cfg.Auth, cfg.ProductCore, auth.RuleAdminOrSubject), idem).
+			Describe(web.EndpointDoc{
+				Summary:     "Update a product",
+				Description: "Send the ETag of the product in If-Match to fail with a 412 when it was changed since.",
+				Tags:        []string{"products"},
+				Request:     productgrp.AppUpdateProduct{},
+				Response:    productgrp.AppProduct{},
+				Status:      http.StatusOK,
+				Auth:        auth.RuleAdminOrSubject,
+			})
+	}
+
+	// The core APIs are served over JSON-RPC for the cores that are provided.
+	if cfg.UserCore != nil || cfg.ProductCore != nil || cfg.SummaryCore != nil {
+		rgh := rpcgrp.New(rpcgrp.Config{
+			Log:         cfg.Log,
+			Auth:        cfg.Auth,
+			UserCore:    cfg.UserCore,
+			ProductCore: cfg.ProductCore,
+			SummaryCore: cfg.SummaryCore,
+		})
+		g.Handle(http.MethodPost, "/rpc", rgh.Serve, authen, limit, idem).
+			Describe(web.EndpointDoc{
+				Summary:     "Call the core APIs over JSON-RPC 2.0",
+				Description: "Executes a call or a batch of calls, like users.query or products.create. Every method authorizes the call itself, failed calls are reported in the body with a 200.",
+				Tags:        []string{"rpc"},
+				Request:     jsonrpc.Request{},
+				Response:    jsonrpc.Response{},
+				Status:      http.StatusOK,
+				Auth:        auth.RuleAuthenticate,
+			})
+	}
+}
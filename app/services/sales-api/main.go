@@ -1,24 +1,57 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ardanlabs/conf/v3"
 	"github.com/farmani/service/app/services/sales-api/handlers"
+	"github.com/farmani/service/business/core/event"
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/product/stores/productdb"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/core/user/stores/userdb"
+	"github.com/farmani/service/business/cview/user/summary"
+	"github.com/farmani/service/business/cview/user/summary/stores/summarydb"
 	database "github.com/farmani/service/business/sys/database/pgx"
 	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/idempotency"
+	"github.com/farmani/service/business/web/idempotency/stores/idempotencydb"
+	"github.com/farmani/service/business/web/lifecycle"
+	"github.com/farmani/service/business/web/metrics"
+	"github.com/farmani/service/business/web/ratelimit"
+	"github.com/farmani/service/business/web/ratelimit/stores/ratelimitdb"
+	"github.com/farmani/service/business/web/ratelimit/stores/ratelimitmem"
+	"github.com/farmani/service/business/web/session"
+	"github.com/farmani/service/business/web/session/stores/sessiondb"
+	"github.com/farmani/service/business/web/session/stores/sessionmem"
 	"github.com/farmani/service/business/web/v1/debug"
+	"github.com/farmani/service/business/web/v1/middlewares"
+	"github.com/farmani/service/foundation/certificate"
 	"github.com/farmani/service/foundation/keystore"
 	"github.com/farmani/service/foundation/logger"
+	"github.com/farmani/service/foundation/vault"
+	"github.com/farmani/service/foundation/web"
+	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/zipkin"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
 )
 
 /*
@@ -64,8 +97,28 @@ func run(log *zap.SugaredLogger) error {
 			WriteTimeout    time.Duration `conf:"default:10s"`
 			IdleTimeout     time.Duration `conf:"default:120s"`
 			ShutdownTimeout time.Duration `conf:"default:20s"`
+			DrainDelay      time.Duration `conf:"default:5s"`
 			APIHost         string        `conf:"default:0.0.0.0:3000"`
 			DebugHost       string        `conf:"default:0.0.0.0:4000"`
+			CompressMinSize int           `conf:"default:1024"`
+			HandlerTimeout  time.Duration `conf:"default:8s"`
+			RouteTimeouts   []string      `conf:"default:GET /v1/events=0s"`
+			MaxInFlight     int           `conf:"default:1000"`
+			MaxStreams      int           `conf:"default:1000"`
+			StreamRoutes    []string      `conf:"default:GET /v1/events"`
+			RetryAfter      time.Duration `conf:"default:1s"`
+			H2C             bool          `conf:"default:false"`
+			LegacyErrors    bool          `conf:"default:false"`
+		}
+		TLS struct {
+			Enabled        bool   `conf:"default:false"`
+			CertFile       string `conf:"default:zarf/tls/tls.crt"`
+			KeyFile        string `conf:"default:zarf/tls/tls.key"`
+			MinVersion     string `conf:"default:1.2"`
+			CipherSuites   []string
+			ReloadInterval time.Duration `conf:"default:1m"`
+			ClientCAFile   string
+			Services       []string
 		}
 		DB struct {
 			User         string `conf:"default:postgres"`
@@ -77,9 +130,64 @@ func run(log *zap.SugaredLogger) error {
 			DisableTLS   bool   `conf:"default:true"`
 		}
 		Auth struct {
-			KeysFolder string `conf:"default:zarf/keys/"`
-			ActiveKID  string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
-			Issuer     string `conf:"default:zarf.sales.api"`
+			KeyStore           string `conf:"default:fs"`
+			KeysFolder         string `conf:"default:zarf/keys/"`
+			KeysPassphrase     string `conf:"mask"`
+			KeysPassphraseFile string
+			SkipInvalidKeys    bool   `conf:"default:false"`
+			ActiveKID          string `conf:"default:54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"`
+			Issuer             string `conf:"default:zarf.sales.api"`
+		}
+		Vault struct {
+			Address        string        `conf:"default:http://vault-service.sales-system.svc.cluster.local:8200"`
+			MountPath      string        `conf:"default:secret"`
+			Path           string        `conf:"default:sales"`
+			Token          string        `conf:"mask"`
+			KubernetesRole string        `conf:""`
+			KubernetesAuth string        `conf:"default:kubernetes"`
+			CacheTTL       time.Duration `conf:"default:5m"`
+		}
+		Sessions struct {
+			Enabled  bool          `conf:"default:false"`
+			Store    string        `conf:"default:postgres"`
+			TTL      time.Duration `conf:"default:30m"`
+			Lifetime time.Duration `conf:"default:12h"`
+			Secure   bool          `conf:"default:true"`
+			SameSite string        `conf:"default:lax"`
+		}
+		RateLimit struct {
+			Enabled        bool     `conf:"default:false"`
+			Store          string   `conf:"default:memory"`
+			Default        string   `conf:"default:100/1m"`
+			Routes         []string `conf:"default:POST /v1/sessions=10/1m"`
+			TrustForwarded bool     `conf:"default:false"`
+		}
+		Idempotency struct {
+			Enabled bool          `conf:"default:false"`
+			TTL     time.Duration `conf:"default:24h"`
+		}
+		Events struct {
+			History   int           `conf:"default:1000"`
+			Buffer    int           `conf:"default:64"`
+			Heartbeat time.Duration `conf:"default:15s"`
+		}
+		CORS struct {
+			AllowedOrigins   []string
+			AllowedMethods   []string      `conf:"default:GET;POST;PUT;PATCH;DELETE"`
+			AllowedHeaders   []string      `conf:"default:Accept;Authorization;Content-Type;Idempotency-Key;If-Match;If-None-Match;Last-Event-ID;X-CSRF-Token;X-Request-ID;traceparent;tracestate"`
+			ExposedHeaders   []string      `conf:"default:X-Request-ID;traceparent;Retry-After;RateLimit-Limit;RateLimit-Remaining;RateLimit-Reset;RateLimit-Policy;Idempotent-Replayed;ETag;WWW-Authenticate"`
+			AllowCredentials bool          `conf:"default:false"`
+			MaxAge           time.Duration `conf:"default:10m"`
+		}
+		Security struct {
+			HSTSMaxAge            time.Duration `conf:"default:8760h"`
+			ContentSecurityPolicy string        `conf:"default:default-src 'none'; frame-ancestors 'none'"`
+		}
+		Tracing struct {
+			Exporter    string  `conf:"default:none"`
+			ReporterURI string  `conf:"default:http://zipkin.sales-system.svc.cluster.local:9411/api/v2/spans"`
+			ServiceName string  `conf:"default:sales-api"`
+			Probability float64 `conf:"default:0.05"`
 		}
 	}{
 		Version: conf.Version{
@@ -102,6 +210,25 @@ func run(log *zap.SugaredLogger) error {
 	}
 	log.Infow("startup", "config", out)
 
+	// -------------------------------------------------------------------------
+	// Lifecycle support
+
+	// Shutdown hooks run in the reverse order they are registered, so the
+	// http server stops first, then the background work, the database and
+	// finally the logger. Stop also runs them when startup fails.
+	lc := lifecycle.NewManager(lifecycle.Config{
+		Log:        log,
+		DrainDelay: cfg.Web.DrainDelay,
+		Timeout:    cfg.Web.ShutdownTimeout,
+	})
+	defer lc.Stop()
+
+	lc.Register("logger", func(ctx context.Context) error {
+		// Syncing stdout fails on some platforms, which isn't worth reporting.
+		_ = log.Sync()
+		return nil
+	})
+
 	// -------------------------------------------------------------------------
 	// Database support
 	log.Infow("startup", "status", "initializing database support", "host", cfg.DB.Host)
@@ -118,17 +245,22 @@ func run(log *zap.SugaredLogger) error {
 	if err != nil {
 		return fmt.Errorf("connecting to db: %w", err)
 	}
-	defer func() {
+	lc.Register("database", func(ctx context.Context) error {
 		log.Infow("shutdown", "status", "stopping database support", "host", cfg.DB.Host)
-		db.Close()
-	}()
+		return db.Close()
+	})
+
+	if err := metrics.RegisterDB(cfg.DB.Name, db.DB); err != nil {
+		return fmt.Errorf("registering db metrics: %w", err)
+	}
+
 	// -------------------------------------------------------------------------
 	// Start Debug Service
 
 	go func() {
 		log.Infow("startup", "status", "debug v1 router started", "host", cfg.Web.DebugHost)
 
-		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Mux(build, log, db)); err != nil {
+		if err := http.ListenAndServe(cfg.Web.DebugHost, debug.Mux(build, log, db, lc)); err != nil {
 			log.Errorw("shutdown", "status", "debug v1 router closed", "host", cfg.Web.DebugHost, "msg", err)
 		}
 	}()
@@ -139,14 +271,58 @@ func run(log *zap.SugaredLogger) error {
 	log.Infow("startup", "status", "initializing authentication.rego support")
 
 	// Simple keystore versus using Vault.
-	ks, err := keystore.NewFS(os.DirFS(cfg.Auth.KeysFolder))
+	var ks auth.KeyLookup
+	switch cfg.Auth.KeyStore {
+	case "fs":
+		passphrase, err := keysPassphrase(cfg.Auth.KeysPassphrase, cfg.Auth.KeysPassphraseFile)
+		if err != nil {
+			return fmt.Errorf("reading keys passphrase: %w", err)
+		}
+
+		fsks, err := keystore.NewFSConfig(os.DirFS(cfg.Auth.KeysFolder), keystore.Config{
+			Passphrase:  passphrase,
+			SkipInvalid: cfg.Auth.SkipInvalidKeys,
+		})
+		if err != nil {
+			return fmt.Errorf("reading keys: %w", err)
+		}
+
+		for _, le := range fsks.LoadErrors() {
+			log.Errorw("startup", "status", "skipping invalid key file", "file", le.File, "ERROR", le.Err)
+		}
+		ks = fsks
+
+	case "vault":
+		log.Infow("startup", "status", "initializing vault key lookup", "address", cfg.Vault.Address)
+
+		vlt, err := vault.New(vault.Config{
+			Address:        cfg.Vault.Address,
+			MountPath:      cfg.Vault.MountPath,
+			Path:           cfg.Vault.Path,
+			Token:          cfg.Vault.Token,
+			KubernetesRole: cfg.Vault.KubernetesRole,
+			KubernetesAuth: cfg.Vault.KubernetesAuth,
+			CacheTTL:       cfg.Vault.CacheTTL,
+			Log:            log.Errorw,
+		})
+		if err != nil {
+			return fmt.Errorf("constructing vault: %w", err)
+		}
+		ks = vlt
+
+	default:
+		return fmt.Errorf("unknown key store %q", cfg.Auth.KeyStore)
+	}
+
+	services, err := auth.ParseServiceIdentities(cfg.TLS.Services)
 	if err != nil {
-		return fmt.Errorf("reading keys: %w", err)
+		return fmt.Errorf("parsing service identities: %w", err)
 	}
 
 	authCfg := auth.Config{
 		Log:       log,
 		KeyLookup: ks,
+		Services:  services,
 	}
 
 	authentication, err := auth.New(authCfg)
@@ -154,6 +330,131 @@ func run(log *zap.SugaredLogger) error {
 		return fmt.Errorf("constructing authentication: %w", err)
 	}
 
+	// -------------------------------------------------------------------------
+	// Initialize event support
+
+	log.Infow("startup", "status", "initializing event support", "history", cfg.Events.History)
+
+	events := event.NewBroadcaster(event.Config{
+		History: cfg.Events.History,
+		Buffer:  cfg.Events.Buffer,
+	})
+
+	// -------------------------------------------------------------------------
+	// Initialize core support
+
+	log.Infow("startup", "status", "initializing core support")
+
+	usrCore := user.NewCore(log, events, userdb.NewStore(log, db))
+	prdCore := product.NewCore(log, events, usrCore, productdb.NewStore(log, db))
+	smmCore := summary.NewCore(summarydb.NewStore(log, db))
+
+	// -------------------------------------------------------------------------
+	// Initialize session support
+
+	var sessions *session.Manager
+	if cfg.Sessions.Enabled {
+		log.Infow("startup", "status", "initializing session support", "store", cfg.Sessions.Store)
+
+		sameSite, err := parseSameSite(cfg.Sessions.SameSite)
+		if err != nil {
+			return fmt.Errorf("parsing session samesite: %w", err)
+		}
+
+		storer := sessionStorer(log, db, cfg.Sessions.Store)
+		if sessStore, ok := storer.(*sessiondb.Store); ok {
+			lc.Go(func(ctx context.Context) {
+				purgeSessions(ctx, log, sessStore)
+			})
+		}
+
+		sessions = session.NewManager(session.Config{
+			Log:      log,
+			Storer:   storer,
+			UserCore: usrCore,
+			TTL:      cfg.Sessions.TTL,
+			Lifetime: cfg.Sessions.Lifetime,
+			Secure:   cfg.Sessions.Secure,
+			SameSite: sameSite,
+		})
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize rate limiting support
+
+	var rateLimiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		log.Infow("startup", "status", "initializing rate limiting support", "store", cfg.RateLimit.Store)
+
+		defQuota, err := ratelimit.ParseQuota(cfg.RateLimit.Default)
+		if err != nil {
+			return fmt.Errorf("parsing default rate limit: %w", err)
+		}
+
+		routeQuotas, err := ratelimit.ParseRouteQuotas(cfg.RateLimit.Routes)
+		if err != nil {
+			return fmt.Errorf("parsing route rate limits: %w", err)
+		}
+
+		var storer ratelimit.Storer
+		switch cfg.RateLimit.Store {
+		case "memory":
+			storer = ratelimitmem.NewStore()
+		case "postgres":
+			rlStore := ratelimitdb.NewStore(log, db)
+			lc.Go(func(ctx context.Context) {
+				purgeRateLimits(ctx, log, rlStore, defQuota, routeQuotas)
+			})
+			storer = rlStore
+		default:
+			return fmt.Errorf("unknown rate limit store %q", cfg.RateLimit.Store)
+		}
+
+		rateLimiter = ratelimit.NewLimiter(ratelimit.Config{
+			Log:            log,
+			Storer:         storer,
+			Default:        defQuota,
+			Routes:         routeQuotas,
+			TrustForwarded: cfg.RateLimit.TrustForwarded,
+		})
+	}
+
+	// -------------------------------------------------------------------------
+	// Initialize idempotency support
+
+	var idempotent *idempotency.Manager
+	if cfg.Idempotency.Enabled {
+		log.Infow("startup", "status", "initializing idempotency support", "ttl", cfg.Idempotency.TTL)
+
+		idemStore := idempotencydb.NewStore(log, db)
+		lc.Go(func(ctx context.Context) {
+			purgeIdempotencyKeys(ctx, log, idemStore)
+		})
+
+		idempotent = idempotency.NewManager(idempotency.Config{
+			Storer: idemStore,
+			TTL:    cfg.Idempotency.TTL,
+		})
+	}
+
+	// -------------------------------------------------------------------------
+	// Start Tracing Support
+
+	log.Infow("startup", "status", "initializing OT/tracing support", "exporter", cfg.Tracing.Exporter)
+
+	traceProvider, err := startTracing(
+		cfg.Tracing.Exporter,
+		cfg.Tracing.ServiceName,
+		cfg.Tracing.ReporterURI,
+		cfg.Tracing.Probability,
+	)
+	if err != nil {
+		return fmt.Errorf("starting tracing: %w", err)
+	}
+	lc.Register("tracing", traceProvider.Shutdown)
+
+	tracer := traceProvider.Tracer("service")
+
 	// -------------------------------------------------
 	// Start Application Service
 	defer log.Infow("Shutdown complete")
@@ -169,27 +470,144 @@ func run(log *zap.SugaredLogger) error {
 		"GOMAXPROCS", runtime.GOMAXPROCS(0),
 	)
 
+	routeTimeouts, err := web.ParseRouteTimeouts(cfg.Web.RouteTimeouts)
+	if err != nil {
+		return fmt.Errorf("parsing route timeouts: %w", err)
+	}
+
+	corsCfg := middlewares.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}
+	if err := corsCfg.Validate(); err != nil {
+		return fmt.Errorf("validating cors config: %w", err)
+	}
+
 	apiMux := handlers.APIMux(handlers.APIMuxConfig{
-		Build:    build,
-		Log:      log,
-		Shutdown: shutdown,
-		Auth:     authentication,
+		Build:       build,
+		Log:         log,
+		Shutdown:    shutdown,
+		Auth:        authentication,
+		Sessions:    sessions,
+		Tracer:      tracer,
+		RateLimiter: rateLimiter,
+		Idempotency: idempotent,
+		Events:      events,
+		Heartbeat:   cfg.Events.Heartbeat,
+		UserCore:    usrCore,
+		ProductCore: prdCore,
+		SummaryCore: smmCore,
+		CompressMin: cfg.Web.CompressMinSize,
+		Timeouts: web.Timeouts{
+			Default: cfg.Web.HandlerTimeout,
+			Routes:  routeTimeouts,
+		},
+		LoadShed: web.LoadShedding{
+			MaxInFlight: cfg.Web.MaxInFlight,
+			MaxStreams:  cfg.Web.MaxStreams,
+			Streams:     cfg.Web.StreamRoutes,
+			RetryAfter:  cfg.Web.RetryAfter,
+		},
+		LegacyErrors: cfg.Web.LegacyErrors,
+		CORS:         corsCfg,
+		Security: middlewares.SecurityConfig{
+			HSTSMaxAge:            cfg.Security.HSTSMaxAge,
+			ContentSecurityPolicy: cfg.Security.ContentSecurityPolicy,
+		},
 	})
 
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
-		Handler:      apiMux,
+		Handler:      lc.Track(apiMux),
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 		IdleTimeout:  cfg.Web.IdleTimeout,
 		ErrorLog:     zap.NewStdLog(log.Desugar()),
 	}
 
+	// -------------------------------------------------------------------------
+	// TLS and HTTP/2 support
+
+	// HTTP/2 is negotiated over TLS by the server. Without TLS, HTTP/2 can
+	// still be accepted in clear text for the traffic inside the cluster.
+	var h2cConns *web.H2C
+
+	switch {
+	case cfg.TLS.Enabled:
+		log.Infow("startup", "status", "initializing tls support", "cert", cfg.TLS.CertFile, "minVersion", cfg.TLS.MinVersion, "clientCA", cfg.TLS.ClientCAFile)
+
+		certs, err := certificate.NewStore(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return fmt.Errorf("loading certificate: %w", err)
+		}
+
+		// Internal services can authenticate with a client certificate
+		// issued by the CAs of the bundle, on the same listener as the
+		// clients using bearer tokens.
+		api.TLSConfig, err = certs.TLSConfig(certificate.Config{
+			MinVersion:   cfg.TLS.MinVersion,
+			CipherSuites: cfg.TLS.CipherSuites,
+			ClientCAFile: cfg.TLS.ClientCAFile,
+		})
+		if err != nil {
+			return fmt.Errorf("configuring tls: %w", err)
+		}
+
+		if err := http2.ConfigureServer(&api, &http2.Server{IdleTimeout: cfg.Web.IdleTimeout}); err != nil {
+			return fmt.Errorf("configuring http/2: %w", err)
+		}
+
+		lc.Go(func(ctx context.Context) {
+			reloadCertificate(ctx, log, certs, cfg.TLS.ReloadInterval)
+		})
+
+	case cfg.Web.H2C:
+		log.Infow("startup", "status", "initializing h2c support")
+
+		h2cConns, err = web.NewH2C(&api, &http2.Server{IdleTimeout: cfg.Web.IdleTimeout})
+		if err != nil {
+			return fmt.Errorf("configuring h2c: %w", err)
+		}
+	}
+
+	// Shutdown doesn't interrupt the requests in flight, so the event
+	// streams are ended for it to finish draining.
+	api.RegisterOnShutdown(events.Close)
+
+	lc.Register("background", lc.StopBackground)
+	lc.Register("http", func(ctx context.Context) error {
+		if err := api.Shutdown(ctx); err != nil {
+			api.Close()
+			if h2cConns != nil {
+				h2cConns.Close()
+			}
+			return fmt.Errorf("could not stop server gracefully: %w", err)
+		}
+
+		// The h2c connections are drained on their own, since the server
+		// lets go of them.
+		if h2cConns != nil {
+			if err := h2cConns.Shutdown(ctx); err != nil {
+				return fmt.Errorf("could not stop h2c connections gracefully: %w", err)
+			}
+		}
+
+		return nil
+	})
+
 	serverErrors := make(chan error, 1)
 
 	go func() {
-		log.Infow("startup", "status", "api router started", "host", api.Addr)
+		log.Infow("startup", "status", "api router started", "host", api.Addr, "tls", cfg.TLS.Enabled)
 
+		if cfg.TLS.Enabled {
+			serverErrors <- api.ListenAndServeTLS("", "")
+			return
+		}
 		serverErrors <- api.ListenAndServe()
 	}()
 
@@ -204,17 +622,219 @@ func run(log *zap.SugaredLogger) error {
 		log.Infow("shutdown", "status", "shutdown started", "signal", sig)
 		defer log.Infow("shutdown", "status", "shutdown complete", "signal", sig)
 
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
-		defer cancel()
-
-		if err := api.Shutdown(ctx); err != nil {
-			err := api.Close()
-			if err != nil {
-				return fmt.Errorf("could not close server gracefully: %w", err)
-			}
-			return fmt.Errorf("could not stop server gracefully: %w", err)
+		if err := lc.Shutdown(); err != nil {
+			return fmt.Errorf("stopping service: %w", err)
 		}
 	}
 
 	return nil
 }
+
+// sessionStorer returns the store to use for sessions. The postgres store is
+// used when the database can be reached, otherwise sessions are kept in memory.
+func sessionStorer(log *zap.SugaredLogger, db *sqlx.DB, store string) session.Storer {
+	if store != "postgres" {
+		return sessionmem.NewStore()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := database.StatusCheck(ctx, db); err != nil {
+		log.Errorw("startup", "status", "session database not ready, falling back to memory store", "ERROR", err)
+		return sessionmem.NewStore()
+	}
+
+	return sessiondb.NewStore(log, db)
+}
+
+// parseSameSite converts the configured samesite mode for cookies.
+func parseSameSite(mode string) (http.SameSite, error) {
+	switch strings.ToLower(mode) {
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+
+	return 0, fmt.Errorf("unknown samesite mode %q", mode)
+}
+
+// keysPassphrase returns the passphrase for encrypted keys from the config
+// value or the contents of the specified file.
+func keysPassphrase(passphrase string, file string) ([]byte, error) {
+	if file == "" {
+		return []byte(passphrase), nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+// startTracing configures open telemetry to export the sampled spans to a
+// zipkin or an otlp/http collector. The exporter "none" records the spans,
+// so trace ids are still generated and linked to the logs, but drops them.
+func startTracing(exporter string, serviceName string, reporterURI string, probability float64) (*trace.TracerProvider, error) {
+	var exp trace.SpanExporter
+
+	switch exporter {
+	case "zipkin":
+		zexp, err := zipkin.New(reporterURI)
+		if err != nil {
+			return nil, fmt.Errorf("creating zipkin exporter: %w", err)
+		}
+		exp = zexp
+
+	case "otlp":
+		u, err := url.Parse(reporterURI)
+		if err != nil {
+			return nil, fmt.Errorf("parsing reporter uri: %w", err)
+		}
+
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(u.Host),
+		}
+		if u.Path != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+		if u.Scheme != "https" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		oexp, err := otlptracehttp.New(context.Background(), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating otlp exporter: %w", err)
+		}
+		exp = oexp
+
+	case "none":
+
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	opts := []trace.TracerProviderOption{
+		trace.WithSampler(trace.ParentBased(trace.TraceIDRatioBased(probability))),
+		trace.WithResource(
+			resource.NewWithAttributes(
+				semconv.SchemaURL,
+				semconv.ServiceName(serviceName),
+			),
+		),
+	}
+	if exp != nil {
+		opts = append(opts, trace.WithBatcher(exp,
+			trace.WithMaxExportBatchSize(trace.DefaultMaxExportBatchSize),
+			trace.WithBatchTimeout(trace.DefaultScheduleDelay*time.Millisecond),
+		))
+	}
+
+	traceProvider := trace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(traceProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return traceProvider, nil
+}
+
+// purgeRateLimits periodically removes the buckets that have been idle for
+// longer than the longest quota period, since they are full again.
+func purgeRateLimits(ctx context.Context, log *zap.SugaredLogger, store *ratelimitdb.Store, def ratelimit.Quota, routes map[string]ratelimit.Quota) {
+	idle := def.Period
+	for _, q := range routes {
+		if q.Period > idle {
+			idle = q.Period
+		}
+	}
+
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := store.Purge(ctx, time.Now().Add(-idle)); err != nil {
+			log.Errorw("ratelimit", "status", "unable to purge buckets", "ERROR", err)
+		}
+		cancel()
+	}
+}
+
+// purgeIdempotencyKeys periodically removes the idempotent requests whose
+// responses are no longer kept for replay.
+func purgeIdempotencyKeys(ctx context.Context, log *zap.SugaredLogger, store *idempotencydb.Store) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := store.Purge(ctx, time.Now()); err != nil {
+			log.Errorw("idempotency", "status", "unable to purge keys", "ERROR", err)
+		}
+		cancel()
+	}
+}
+
+// purgeSessions periodically removes the sessions that expired without being
+// used again, since those are only deleted on lookup.
+func purgeSessions(ctx context.Context, log *zap.SugaredLogger, store *sessiondb.Store) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		if err := store.Purge(ctx, time.Now()); err != nil {
+			log.Errorw("session", "status", "unable to purge sessions", "ERROR", err)
+		}
+		cancel()
+	}
+}
+
+// reloadCertificate periodically checks the files of the certificate and
+// loads it again when they change, so a rotated certificate is served without
+// a restart.
+func reloadCertificate(ctx context.Context, log *zap.SugaredLogger, certs *certificate.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		reloaded, err := certs.Reload()
+		if err != nil {
+			log.Errorw("tls", "status", "unable to reload certificate", "ERROR", err)
+			continue
+		}
+
+		if reloaded {
+			log.Infow("tls", "status", "certificate reloaded")
+		}
+	}
+}
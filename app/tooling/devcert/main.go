@@ -0,0 +1,59 @@
+// This program generates a self-signed certificate for serving the sales-api
+// over TLS during development.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/farmani/service/foundation/certificate"
+)
+
+var (
+	dir      string
+	hosts    string
+	validFor time.Duration
+)
+
+func init() {
+	flag.StringVar(&dir, "dir", "zarf/tls", "folder to write tls.crt and tls.key to")
+	flag.StringVar(&hosts, "hosts", "localhost,127.0.0.1,sales-service.sales-system.svc.cluster.local", "comma separated names and ips the certificate is valid for")
+	flag.DurationVar(&validFor, "valid-for", 365*24*time.Hour, "how long the certificate is valid for")
+}
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func run() error {
+	certPEM, keyPEM, err := certificate.GenerateSelfSigned(strings.Split(hosts, ","), validFor)
+	if err != nil {
+		return fmt.Errorf("generating certificate: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating folder: %w", err)
+	}
+
+	certFile := filepath.Join(dir, "tls.crt")
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return fmt.Errorf("writing certificate: %w", err)
+	}
+
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return fmt.Errorf("writing key: %w", err)
+	}
+
+	fmt.Println("certificate written to", certFile, "and", keyFile)
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package event provides an in-process broadcaster for the changes made by
+// the core business APIs. Subscribers receive the events published after they
+// subscribed, and recent events are kept so a subscriber that reconnects can
+// resume where it left off.
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// Config represents information required to initialize the broadcaster.
+// History is the number of recent events kept for resuming and Buffer the
+// number of events a subscriber can fall behind before it is dropped.
+type Config struct {
+	History int
+	Buffer  int
+}
+
+// Broadcaster manages the set of APIs for publishing events to subscribers.
+// A nil broadcaster accepts events and discards them.
+type Broadcaster struct {
+	buffer int
+
+	mu      sync.Mutex
+	seq     uint64
+	history []Event
+	next    int
+	full    bool
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// NewBroadcaster constructs a broadcaster for event api access.
+func NewBroadcaster(cfg Config) *Broadcaster {
+	if cfg.Buffer < 1 {
+		cfg.Buffer = 1
+	}
+
+	return &Broadcaster{
+		buffer:  cfg.Buffer,
+		history: make([]Event, cfg.History),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next ID to the event and sends it to the subscribers.
+// Subscribers that have fallen too far behind are dropped instead of slowing
+// down the publisher.
+func (b *Broadcaster) Publish(evt Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.seq++
+	evt.ID = b.seq
+	if evt.Date.IsZero() {
+		evt.Date = time.Now()
+	}
+
+	if len(b.history) > 0 {
+		b.history[b.next] = evt
+		b.next = (b.next + 1) % len(b.history)
+		if b.next == 0 {
+			b.full = true
+		}
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- evt:
+		default:
+			b.drop(sub, true)
+		}
+	}
+}
+
+// Subscribe starts receiving the events published from now on. The events
+// kept in the history with an ID after the specified one are returned to be
+// sent first. False is returned when some of the events after that ID are no
+// longer kept, so the subscriber knows it missed events, or when the ID was
+// never published, like one from before a restart.
+func (b *Broadcaster) Subscribe(after uint64) (*Subscription, []Event, bool) {
+	sub := Subscription{
+		b:  b,
+		ch: make(chan Event, b.buffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return &sub, nil, true
+	}
+
+	b.subs[&sub] = struct{}{}
+
+	switch {
+	case after == 0 || after == b.seq:
+		return &sub, nil, true
+	case after > b.seq:
+		return &sub, nil, false
+	}
+
+	var missed []Event
+	for _, evt := range b.recent() {
+		if evt.ID > after {
+			missed = append(missed, evt)
+		}
+	}
+
+	complete := len(missed) > 0 && missed[0].ID == after+1
+
+	return &sub, missed, complete
+}
+
+// Close ends every subscription and stops accepting events. It's used on
+// shutdown so long running streams return.
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub, false)
+	}
+}
+
+// recent returns the events kept in the history, oldest first.
+func (b *Broadcaster) recent() []Event {
+	if !b.full {
+		return append([]Event(nil), b.history[:b.next]...)
+	}
+
+	return append(append([]Event(nil), b.history[b.next:]...), b.history[:b.next]...)
+}
+
+// drop removes the subscription and closes its channel.
+func (b *Broadcaster) drop(sub *Subscription, lagged bool) {
+	if _, exists := b.subs[sub]; !exists {
+		return
+	}
+
+	delete(b.subs, sub)
+	sub.lagged = lagged
+	close(sub.ch)
+}
+
+// =============================================================================
+
+// Subscription represents a subscriber to the events of a broadcaster.
+type Subscription struct {
+	b      *Broadcaster
+	ch     chan Event
+	lagged bool
+}
+
+// Events returns the channel the events are received on. It's closed when the
+// subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Lagged reports if the subscription was ended because the subscriber fell
+// too far behind. The subscriber can subscribe again to resume.
+func (s *Subscription) Lagged() bool {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	return s.lagged
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+
+	s.b.drop(s, false)
+}
@@ -0,0 +1,33 @@
+package event
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Set of domains that publish events.
+const (
+	DomainUser    = "user"
+	DomainProduct = "product"
+)
+
+// Set of actions that are published.
+const (
+	ActionCreated = "created"
+	ActionUpdated = "updated"
+	ActionDeleted = "deleted"
+)
+
+// Event represents a change made to an entity of a domain. UserID is the user
+// owning the entity and is used to decide who can see the event. Data holds
+// the entity as it is after the change.
+type Event struct {
+	ID       uint64
+	Domain   string
+	Action   string
+	EntityID uuid.UUID
+	UserID   uuid.UUID
+	Data     any
+	Date     time.Time
+}
@@ -3,16 +3,18 @@ package product
 import (
 	"fmt"
 
+	"github.com/farmani/service/business/data/access"
 	"github.com/farmani/service/business/sys/validate"
 	"github.com/google/uuid"
 )
 
 // QueryFilter holds the available fields a query can be filtered on.
 type QueryFilter struct {
-	ID       *uuid.UUID `validate:"omitempty"`
-	Name     *string    `validate:"omitempty,min=3"`
-	Cost     *float64   `validate:"omitempty,numeric"`
-	Quantity *int       `validate:"omitempty,numeric"`
+	ID       *uuid.UUID    `validate:"omitempty"`
+	Name     *string       `validate:"omitempty,min=3"`
+	Cost     *float64      `validate:"omitempty,numeric"`
+	Quantity *int          `validate:"omitempty,numeric"`
+	Access   access.Filter `validate:"-"`
 }
 
 // Validate checks the data in the model is considered clean.
@@ -42,3 +44,10 @@ func (qf *QueryFilter) WithCost(cost float64) {
 func (qf *QueryFilter) WithQuantity(quantity int) {
 	qf.Quantity = &quantity
 }
+
+// WithAccess sets the Access field of the QueryFilter value which restricts
+// the rows to the ones the caller is allowed to see. A filter without access
+// returns no rows, pass access.All for a query that isn't restricted.
+func (qf *QueryFilter) WithAccess(filter access.Filter) {
+	qf.Access = filter
+}
@@ -13,6 +13,7 @@ type Product struct {
 	Name        string
 	Cost        float64
 	Quantity    int
+	Version     int
 	DateCreated time.Time
 	DateUpdated time.Time
 }
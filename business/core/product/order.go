@@ -8,9 +8,9 @@ var DefaultOrderBy = order.NewBy(OrderByID, order.ASC)
 // Set of fields that the results can be ordered by. These are the names
 // that should be used by the application layer.
 const (
-	OrderByID      = "user_id"
-	OrderByName    = "name"
-	OrderByEmail   = "email"
-	OrderByRoles   = "roles"
-	OrderByEnabled = "enabled"
+	OrderByID       = "product_id"
+	OrderByUserID   = "user_id"
+	OrderByName     = "name"
+	OrderByCost     = "cost"
+	OrderByQuantity = "quantity"
 )
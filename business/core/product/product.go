@@ -9,10 +9,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/farmani/service/business/core/event"
 	"github.com/farmani/service/business/core/user"
 	"github.com/farmani/service/business/data/order"
-	"github.com/farmani/service/foundation/logger"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 // Set of error variables for CRUD operations.
@@ -20,12 +21,14 @@ var (
 	ErrNotFound    = errors.New("product not found")
 	ErrInvalidUser = errors.New("user not valid")
 	ErrInvalidCost = errors.New("cost not valid")
+	ErrConflict    = errors.New("product was changed since it was read")
 )
 
 // =============================================================================
 
 // Storer interface declares the behavior this package needs to perists and
-// retrieve data.
+// retrieve data. Update must only change the product when its version in the
+// store still matches and return ErrConflict otherwise.
 type Storer interface {
 	Create(ctx context.Context, prd Product) error
 	Update(ctx context.Context, prd Product) error
@@ -46,15 +49,18 @@ type UserCore interface {
 
 // Core manages the set of APIs for product access.
 type Core struct {
-	log     *logger.Logger
+	log     *zap.SugaredLogger
+	evn     *event.Broadcaster
 	usrCore UserCore
 	storer  Storer
 }
 
-// NewCore constructs a core for product api access.
-func NewCore(log *logger.Logger, usrCore UserCore, storer Storer) *Core {
+// NewCore constructs a core for product api access. The changes made to
+// products are published to the broadcaster, which can be nil.
+func NewCore(log *zap.SugaredLogger, evn *event.Broadcaster, usrCore UserCore, storer Storer) *Core {
 	c := Core{
 		log:     log,
+		evn:     evn,
 		usrCore: usrCore,
 		storer:  storer,
 	}
@@ -85,6 +91,7 @@ func (c *Core) Create(ctx context.Context, np NewProduct) (Product, error) {
 		Cost:        np.Cost,
 		Quantity:    np.Quantity,
 		UserID:      np.UserID,
+		Version:     1,
 		DateCreated: now,
 		DateUpdated: now,
 	}
@@ -93,10 +100,13 @@ func (c *Core) Create(ctx context.Context, np NewProduct) (Product, error) {
 		return Product{}, fmt.Errorf("create: %w", err)
 	}
 
+	c.publish(event.ActionCreated, prd)
+
 	return prd, nil
 }
 
-// Update modifies information about a product.
+// Update modifies information about a product. ErrConflict is returned when
+// the product was changed by someone else since it was read.
 func (c *Core) Update(ctx context.Context, prd Product, up UpdateProduct) (Product, error) {
 	if up.Name != nil {
 		prd.Name = *up.Name
@@ -116,6 +126,10 @@ func (c *Core) Update(ctx context.Context, prd Product, up UpdateProduct) (Produ
 		return Product{}, fmt.Errorf("update: %w", err)
 	}
 
+	prd.Version++
+
+	c.publish(event.ActionUpdated, prd)
+
 	return prd, nil
 }
 
@@ -125,6 +139,8 @@ func (c *Core) Delete(ctx context.Context, prd Product) error {
 		return fmt.Errorf("delete: %w", err)
 	}
 
+	c.publish(event.ActionDeleted, prd)
+
 	return nil
 }
 
@@ -162,3 +178,14 @@ func (c *Core) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]Product,
 
 	return prds, nil
 }
+
+// publish sends the change made to the product to the subscribers.
+func (c *Core) publish(action string, prd Product) {
+	c.evn.Publish(event.Event{
+		Domain:   event.DomainProduct,
+		Action:   action,
+		EntityID: prd.ID,
+		UserID:   prd.UserID,
+		Data:     prd,
+	})
+}
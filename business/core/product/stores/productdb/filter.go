@@ -0,0 +1,54 @@
+package productdb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/farmani/service/business/core/product"
+)
+
+// accessFields maps the row fields used by access rules to columns.
+var accessFields = map[string]string{
+	"UserID": "user_id",
+}
+
+func (s *Store) applyFilter(filter product.QueryFilter, data map[string]interface{}, buf *bytes.Buffer) error {
+	var wc []string
+
+	if filter.ID != nil {
+		data["product_id"] = *filter.ID
+		wc = append(wc, "product_id = :product_id")
+	}
+
+	if filter.Name != nil {
+		data["name"] = fmt.Sprintf("%%%s%%", *filter.Name)
+		wc = append(wc, "name LIKE :name")
+	}
+
+	if filter.Cost != nil {
+		data["cost"] = *filter.Cost
+		wc = append(wc, "cost = :cost")
+	}
+
+	if filter.Quantity != nil {
+		data["quantity"] = *filter.Quantity
+		wc = append(wc, "quantity = :quantity")
+	}
+
+	access, err := filter.Access.Where(accessFields, data)
+	if err != nil {
+		return fmt.Errorf("access: %w", err)
+	}
+
+	if access != "" {
+		wc = append(wc, access)
+	}
+
+	if len(wc) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(strings.Join(wc, " AND "))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package productdb
+
+import (
+	"time"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/google/uuid"
+)
+
+// dbProduct represents an individual product.
+type dbProduct struct {
+	ID          uuid.UUID `db:"product_id"`
+	UserID      uuid.UUID `db:"user_id"`
+	Name        string    `db:"name"`
+	Cost        float64   `db:"cost"`
+	Quantity    int       `db:"quantity"`
+	Version     int       `db:"version"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+func toDBProduct(prd product.Product) dbProduct {
+	return dbProduct{
+		ID:          prd.ID,
+		UserID:      prd.UserID,
+		Name:        prd.Name,
+		Cost:        prd.Cost,
+		Quantity:    prd.Quantity,
+		Version:     prd.Version,
+		DateCreated: prd.DateCreated.UTC(),
+		DateUpdated: prd.DateUpdated.UTC(),
+	}
+}
+
+func toCoreProduct(dbPrd dbProduct) product.Product {
+	return product.Product{
+		ID:          dbPrd.ID,
+		UserID:      dbPrd.UserID,
+		Name:        dbPrd.Name,
+		Cost:        dbPrd.Cost,
+		Quantity:    dbPrd.Quantity,
+		Version:     dbPrd.Version,
+		DateCreated: dbPrd.DateCreated.In(time.Local),
+		DateUpdated: dbPrd.DateUpdated.In(time.Local),
+	}
+}
+
+func toCoreProductSlice(dbProducts []dbProduct) []product.Product {
+	prds := make([]product.Product, len(dbProducts))
+	for i, dbPrd := range dbProducts {
+		prds[i] = toCoreProduct(dbPrd)
+	}
+	return prds
+}
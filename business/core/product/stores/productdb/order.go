@@ -0,0 +1,25 @@
+package productdb
+
+import (
+	"fmt"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/data/order"
+)
+
+var orderByFields = map[string]string{
+	product.OrderByID:       "product_id",
+	product.OrderByUserID:   "user_id",
+	product.OrderByName:     "name",
+	product.OrderByCost:     "cost",
+	product.OrderByQuantity: "quantity",
+}
+
+func orderByClause(orderBy order.By) (string, error) {
+	by, exists := orderByFields[orderBy.Field]
+	if !exists {
+		return "", fmt.Errorf("field %q does not exist", orderBy.Field)
+	}
+
+	return " ORDER BY " + by + " " + orderBy.Direction, nil
+}
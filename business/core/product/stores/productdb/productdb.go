@@ -0,0 +1,207 @@
+// Package productdb contains product related CRUD functionality.
+package productdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/data/order"
+	database "github.com/farmani/service/business/sys/database/pgx"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for product database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create adds a Product to the database.
+func (s *Store) Create(ctx context.Context, prd product.Product) error {
+	const q = `
+	INSERT INTO products
+		(product_id, user_id, name, cost, quantity, version, date_created, date_updated)
+	VALUES
+		(:product_id, :user_id, :name, :cost, :quantity, :version, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBProduct(prd)); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
+
+// Update modifies data about a product when the version of the stored
+// product still matches, bumping the version.
+func (s *Store) Update(ctx context.Context, prd product.Product) error {
+	const q = `
+	UPDATE
+		products
+	SET
+		"name" = :name,
+		"cost" = :cost,
+		"quantity" = :quantity,
+		"version" = version + 1,
+		"date_updated" = :date_updated
+	WHERE
+		product_id = :product_id AND
+		version = :version
+	RETURNING
+		version`
+
+	var dbVersion struct {
+		Version int `db:"version"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, toDBProduct(prd), &dbVersion); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("namedquerystruct: %w", product.ErrConflict)
+		}
+		return fmt.Errorf("namedquerystruct: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the product identified by a given ID.
+func (s *Store) Delete(ctx context.Context, prd product.Product) error {
+	data := struct {
+		ID string `db:"product_id"`
+	}{
+		ID: prd.ID.String(),
+	}
+
+	const q = `
+	DELETE FROM
+		products
+	WHERE
+		product_id = :product_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
+
+// Query gets all Products from the database.
+func (s *Store) Query(ctx context.Context, filter product.QueryFilter, orderBy order.By, pageNumber int, rowsPerPage int) ([]product.Product, error) {
+	data := map[string]interface{}{
+		"offset":        (pageNumber - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	const q = `
+	SELECT
+		product_id, user_id, name, cost, quantity, version, date_created, date_updated
+	FROM
+		products`
+
+	buf := bytes.NewBufferString(q)
+	if err := s.applyFilter(filter, data, buf); err != nil {
+		return nil, err
+	}
+
+	orderByClause, err := orderByClause(orderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.WriteString(orderByClause)
+	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
+
+	var dbPrds []dbProduct
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &dbPrds); err != nil {
+		return nil, fmt.Errorf("namedqueryslice: %w", err)
+	}
+
+	return toCoreProductSlice(dbPrds), nil
+}
+
+// Count returns the total number of products in the DB.
+func (s *Store) Count(ctx context.Context, filter product.QueryFilter) (int, error) {
+	data := map[string]interface{}{}
+
+	const q = `
+	SELECT
+		count(1)
+	FROM
+		products`
+
+	buf := bytes.NewBufferString(q)
+	if err := s.applyFilter(filter, data, buf); err != nil {
+		return 0, err
+	}
+
+	var count struct {
+		Count int `db:"count"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+		return 0, fmt.Errorf("namedquerystruct: %w", err)
+	}
+
+	return count.Count, nil
+}
+
+// QueryByID finds the product identified by a given ID.
+func (s *Store) QueryByID(ctx context.Context, productID uuid.UUID) (product.Product, error) {
+	data := struct {
+		ID string `db:"product_id"`
+	}{
+		ID: productID.String(),
+	}
+
+	const q = `
+	SELECT
+		product_id, user_id, name, cost, quantity, version, date_created, date_updated
+	FROM
+		products
+	WHERE
+		product_id = :product_id`
+
+	var dbPrd dbProduct
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbPrd); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return product.Product{}, fmt.Errorf("namedquerystruct: %w", product.ErrNotFound)
+		}
+		return product.Product{}, fmt.Errorf("namedquerystruct: %w", err)
+	}
+
+	return toCoreProduct(dbPrd), nil
+}
+
+// QueryByUserID finds the products owned by the specified user.
+func (s *Store) QueryByUserID(ctx context.Context, userID uuid.UUID) ([]product.Product, error) {
+	data := struct {
+		ID string `db:"user_id"`
+	}{
+		ID: userID.String(),
+	}
+
+	const q = `
+	SELECT
+		product_id, user_id, name, cost, quantity, version, date_created, date_updated
+	FROM
+		products
+	WHERE
+		user_id = :user_id`
+
+	var dbPrds []dbProduct
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &dbPrds); err != nil {
+		return nil, fmt.Errorf("namedqueryslice: %w", err)
+	}
+
+	return toCoreProductSlice(dbPrds), nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/mail"
 	"time"
 
+	"github.com/farmani/service/business/data/access"
 	"github.com/farmani/service/business/sys/validate"
 	"github.com/google/uuid"
 )
@@ -16,6 +17,7 @@ type QueryFilter struct {
 	Email            *mail.Address `validate:"omitempty"`
 	StartCreatedDate *time.Time    `validate:"omitempty"`
 	EndCreatedDate   *time.Time    `validate:"omitempty"`
+	Access           access.Filter `validate:"-"`
 }
 
 // Validate checks the data in the model is considered clean.
@@ -52,3 +54,10 @@ func (qf *QueryFilter) WithEndCreatedDate(endDate time.Time) {
 	d := endDate.UTC()
 	qf.EndCreatedDate = &d
 }
+
+// WithAccess sets the Access field of the QueryFilter value which restricts
+// the rows to the ones the caller is allowed to see. A filter without access
+// returns no rows, pass access.All for a query that isn't restricted.
+func (qf *QueryFilter) WithAccess(filter access.Filter) {
+	qf.Access = filter
+}
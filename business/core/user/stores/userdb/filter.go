@@ -8,7 +8,12 @@ import (
 	"github.com/farmani/service/business/core/user"
 )
 
-func (s *Store) applyFilter(filter user.QueryFilter, data map[string]interface{}, buf *bytes.Buffer) {
+// accessFields maps the row fields used by access rules to columns.
+var accessFields = map[string]string{
+	"UserID": "user_id",
+}
+
+func (s *Store) applyFilter(filter user.QueryFilter, data map[string]interface{}, buf *bytes.Buffer) error {
 	var wc []string
 
 	if filter.ID != nil {
@@ -22,7 +27,7 @@ func (s *Store) applyFilter(filter user.QueryFilter, data map[string]interface{}
 	}
 
 	if filter.Email != nil {
-		data["email"] = (*filter.Email).String()
+		data["email"] = filter.Email.Address
 		wc = append(wc, "email = :email")
 	}
 
@@ -36,8 +41,19 @@ func (s *Store) applyFilter(filter user.QueryFilter, data map[string]interface{}
 		wc = append(wc, "date_created <= :end_date_created")
 	}
 
+	access, err := filter.Access.Where(accessFields, data)
+	if err != nil {
+		return fmt.Errorf("access: %w", err)
+	}
+
+	if access != "" {
+		wc = append(wc, access)
+	}
+
 	if len(wc) > 0 {
 		buf.WriteString(" WHERE ")
 		buf.WriteString(strings.Join(wc, " AND "))
 	}
+
+	return nil
 }
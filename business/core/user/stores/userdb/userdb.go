@@ -11,52 +11,37 @@ import (
 
 	"github.com/farmani/service/business/core/user"
 	"github.com/farmani/service/business/data/order"
+	database "github.com/farmani/service/business/sys/database/pgx"
 	"github.com/farmani/service/business/sys/database/pgx/dbarray"
-	"github.com/farmani/service/foundation/logger"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
 )
 
 // Store manages the set of APIs for user database access.
 type Store struct {
-	log *logger.Logger
+	log *zap.SugaredLogger
 	db  sqlx.ExtContext
 }
 
 // NewStore constructs the api for data access.
-func NewStore(log *logger.Logger, db *sqlx.DB) *Store {
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
 	return &Store{
 		log: log,
 		db:  db,
 	}
 }
 
-// ExecuteUnderTransaction constructs a new Store value replacing the sqlx DB
-// value with a sqlx DB value that is currently inside a transaction.
-func (s *Store) ExecuteUnderTransaction(tx transaction.Transaction) (user.Storer, error) {
-	ec, err := db.GetExtContext(tx)
-	if err != nil {
-		return nil, err
-	}
-
-	s = &Store{
-		log: s.log,
-		db:  ec,
-	}
-
-	return s, nil
-}
-
 // Create inserts a new user into the database.
 func (s *Store) Create(ctx context.Context, usr user.User) error {
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated)
+		(user_id, name, email, password_hash, roles, enabled, department, version, date_created, date_updated)
 	VALUES
-		(:user_id, :name, :email, :password_hash, :roles, :enabled, :department, :date_created, :date_updated)`
+		(:user_id, :name, :email, :password_hash, :roles, :enabled, :department, :version, :date_created, :date_updated)`
 
-	if err := db.NamedExecContext(ctx, s.log, s.db, q, toDBUser(usr)); err != nil {
-		if errors.Is(err, db.ErrDBDuplicatedEntry) {
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBUser(usr)); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
 			return fmt.Errorf("namedexeccontext: %w", user.ErrUniqueEmail)
 		}
 		return fmt.Errorf("namedexeccontext: %w", err)
@@ -65,7 +50,8 @@ func (s *Store) Create(ctx context.Context, usr user.User) error {
 	return nil
 }
 
-// Update replaces a user document in the database.
+// Update replaces a user document in the database when the version of the
+// stored user still matches, bumping the version.
 func (s *Store) Update(ctx context.Context, usr user.User) error {
 	const q = `
 	UPDATE
@@ -76,15 +62,26 @@ func (s *Store) Update(ctx context.Context, usr user.User) error {
 		"roles" = :roles,
 		"password_hash" = :password_hash,
 		"department" = :department,
+		"enabled" = :enabled,
+		"version" = version + 1,
 		"date_updated" = :date_updated
 	WHERE
-		user_id = :user_id`
+		user_id = :user_id AND
+		version = :version
+	RETURNING
+		version`
 
-	if err := db.NamedExecContext(ctx, s.log, s.db, q, toDBUser(usr)); err != nil {
-		if errors.Is(err, db.ErrDBDuplicatedEntry) {
+	var dbVersion struct {
+		Version int `db:"version"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, toDBUser(usr), &dbVersion); err != nil {
+		switch {
+		case errors.Is(err, database.ErrDBDuplicatedEntry):
 			return user.ErrUniqueEmail
+		case errors.Is(err, database.ErrDBNotFound):
+			return fmt.Errorf("namedquerystruct: %w", user.ErrConflict)
 		}
-		return fmt.Errorf("namedexeccontext: %w", err)
+		return fmt.Errorf("namedquerystruct: %w", err)
 	}
 
 	return nil
@@ -104,7 +101,7 @@ func (s *Store) Delete(ctx context.Context, usr user.User) error {
 	WHERE
 		user_id = :user_id`
 
-	if err := db.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
 		return fmt.Errorf("namedexeccontext: %w", err)
 	}
 
@@ -120,12 +117,14 @@ func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy orde
 
 	const q = `
 	SELECT
-		user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated
+		user_id, name, email, password_hash, roles, enabled, department, version, date_created, date_updated
 	FROM
 		users`
 
 	buf := bytes.NewBufferString(q)
-	s.applyFilter(filter, data, buf)
+	if err := s.applyFilter(filter, data, buf); err != nil {
+		return nil, err
+	}
 
 	orderByClause, err := orderByClause(orderBy)
 	if err != nil {
@@ -136,7 +135,7 @@ func (s *Store) Query(ctx context.Context, filter user.QueryFilter, orderBy orde
 	buf.WriteString(" OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY")
 
 	var dbUsrs []dbUser
-	if err := db.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &dbUsrs); err != nil {
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, buf.String(), data, &dbUsrs); err != nil {
 		return nil, fmt.Errorf("namedqueryslice: %w", err)
 	}
 
@@ -159,12 +158,14 @@ func (s *Store) Count(ctx context.Context, filter user.QueryFilter) (int, error)
 		users`
 
 	buf := bytes.NewBufferString(q)
-	s.applyFilter(filter, data, buf)
+	if err := s.applyFilter(filter, data, buf); err != nil {
+		return 0, err
+	}
 
 	var count struct {
 		Count int `db:"count"`
 	}
-	if err := db.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, buf.String(), data, &count); err != nil {
 		return 0, fmt.Errorf("namedquerystruct: %w", err)
 	}
 
@@ -181,15 +182,15 @@ func (s *Store) QueryByID(ctx context.Context, userID uuid.UUID) (user.User, err
 
 	const q = `
 	SELECT
-        user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated
+        user_id, name, email, password_hash, roles, enabled, department, version, date_created, date_updated
 	FROM
 		users
 	WHERE
 		user_id = :user_id`
 
 	var dbUsr dbUser
-	if err := db.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbUsr); err != nil {
-		if errors.Is(err, db.ErrDBNotFound) {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbUsr); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
 			return user.User{}, fmt.Errorf("namedquerystruct: %w", user.ErrNotFound)
 		}
 		return user.User{}, fmt.Errorf("namedquerystruct: %w", err)
@@ -221,15 +222,15 @@ func (s *Store) QueryByIDs(ctx context.Context, userIDs []uuid.UUID) ([]user.Use
 
 	const q = `
 	SELECT
-        user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated
+        user_id, name, email, password_hash, roles, enabled, department, version, date_created, date_updated
 	FROM
 		users
 	WHERE
 		user_id = ANY(:user_id)`
 
 	var dbUsrs []dbUser
-	if err := db.NamedQuerySlice(ctx, s.log, s.db, q, data, &dbUsrs); err != nil {
-		if errors.Is(err, db.ErrDBNotFound) {
+	if err := database.NamedQuerySlice(ctx, s.log, s.db, q, data, &dbUsrs); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
 			return nil, user.ErrNotFound
 		}
 		return nil, fmt.Errorf("namedquerystruct: %w", err)
@@ -253,15 +254,15 @@ func (s *Store) QueryByEmail(ctx context.Context, email mail.Address) (user.User
 
 	const q = `
 	SELECT
-        user_id, name, email, password_hash, roles, enabled, department, date_created, date_updated
+        user_id, name, email, password_hash, roles, enabled, department, version, date_created, date_updated
 	FROM
 		users
 	WHERE
 		email = :email`
 
 	var dbUsr dbUser
-	if err := db.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbUsr); err != nil {
-		if errors.Is(err, db.ErrDBNotFound) {
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbUsr); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
 			return user.User{}, fmt.Errorf("namedquerystruct: %w", user.ErrNotFound)
 		}
 		return user.User{}, fmt.Errorf("namedquerystruct: %w", err)
@@ -10,6 +10,7 @@ import (
 	"net/mail"
 	"time"
 
+	"github.com/farmani/service/business/core/event"
 	"github.com/farmani/service/business/data/order"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -19,13 +20,15 @@ import (
 var (
 	ErrNotFound              = errors.New("user not found")
 	ErrUniqueEmail           = errors.New("email is not unique")
+	ErrConflict              = errors.New("user was changed since it was read")
 	ErrAuthenticationFailure = errors.New("authentication.rego failed")
 )
 
 // =============================================================================
 
 // Storer interface declares the behavior this package needs to persists and
-// retrieve data.
+// retrieve data. Update must only change the user when its version in the
+// store still matches and return ErrConflict otherwise.
 type Storer interface {
 	Create(ctx context.Context, usr User) error
 	Update(ctx context.Context, usr User) error
@@ -42,13 +45,16 @@ type Storer interface {
 // Core manages the set of APIs for user access.
 type Core struct {
 	storer Storer
+	evn    *event.Broadcaster
 	log    *zap.SugaredLogger
 }
 
-// NewCore constructs a core for user api access.
-func NewCore(log *zap.SugaredLogger, storer Storer) *Core {
+// NewCore constructs a core for user api access. The changes made to users
+// are published to the broadcaster, which can be nil.
+func NewCore(log *zap.SugaredLogger, evn *event.Broadcaster, storer Storer) *Core {
 	return &Core{
 		storer: storer,
+		evn:    evn,
 		log:    log,
 	}
 }
@@ -66,10 +72,11 @@ func (c *Core) Create(ctx context.Context, cu CreateUser) (User, error) {
 		ID:           uuid.New(),
 		Name:         cu.Name,
 		Email:        cu.Email,
-		PasswordHash: password,
+		PasswordHash: password.Hash,
 		Roles:        cu.Roles,
 		Department:   cu.Department,
 		Enabled:      true,
+		Version:      1,
 		DateCreated:  now,
 		DateUpdated:  now,
 	}
@@ -78,10 +85,13 @@ func (c *Core) Create(ctx context.Context, cu CreateUser) (User, error) {
 		return User{}, fmt.Errorf("creating user: %w", err)
 	}
 
+	c.publish(event.ActionCreated, usr)
+
 	return usr, nil
 }
 
-// Update modifies information about a user.
+// Update modifies information about a user. ErrConflict is returned when the
+// user was changed by someone else since it was read.
 func (c *Core) Update(ctx context.Context, user User, updateUser UpdateUser) (User, error) {
 	if updateUser.Name != nil {
 		user.Name = *updateUser.Name
@@ -108,7 +118,7 @@ func (c *Core) Update(ctx context.Context, user User, updateUser UpdateUser) (Us
 		if err := pw.set(*updateUser.Password); err != nil {
 			return User{}, fmt.Errorf("generating password hash: %w", err)
 		}
-		user.PasswordHash = pw
+		user.PasswordHash = pw.Hash
 	}
 
 	user.DateUpdated = time.Now()
@@ -117,6 +127,10 @@ func (c *Core) Update(ctx context.Context, user User, updateUser UpdateUser) (Us
 		return User{}, fmt.Errorf("update: %w", err)
 	}
 
+	user.Version++
+
+	c.publish(event.ActionUpdated, user)
+
 	return user, nil
 }
 
@@ -126,6 +140,8 @@ func (c *Core) Delete(ctx context.Context, usr User) error {
 		return fmt.Errorf("delete: %w", err)
 	}
 
+	c.publish(event.ActionDeleted, usr)
+
 	return nil
 }
 
@@ -186,7 +202,7 @@ func (c *Core) Authenticate(ctx context.Context, email mail.Address, password st
 		return User{}, fmt.Errorf("query: email[%s]: %w", email, err)
 	}
 
-	pw := usr.PasswordHash
+	pw := Password{Hash: usr.PasswordHash}
 	res, err := pw.Matches(password)
 	if err != nil || !res {
 		return User{}, fmt.Errorf("comparehashpassword: %w", ErrAuthenticationFailure)
@@ -194,3 +210,15 @@ func (c *Core) Authenticate(ctx context.Context, email mail.Address, password st
 
 	return usr, nil
 }
+
+// publish sends the change made to the user to the subscribers. A user owns
+// itself, so it can see its own changes.
+func (c *Core) publish(action string, usr User) {
+	c.evn.Publish(event.Event{
+		Domain:   event.DomainUser,
+		Action:   action,
+		EntityID: usr.ID,
+		UserID:   usr.ID,
+		Data:     usr,
+	})
+}
@@ -0,0 +1,31 @@
+package summarydb
+
+import (
+	"github.com/farmani/service/business/cview/user/summary"
+	"github.com/google/uuid"
+)
+
+// dbSummary represents an individual row of the user summary view.
+type dbSummary struct {
+	UserID     uuid.UUID `db:"user_id"`
+	UserName   string    `db:"user_name"`
+	TotalCount int       `db:"total_count"`
+	TotalCost  float64   `db:"total_cost"`
+}
+
+func toCoreSummary(dbSmm dbSummary) summary.Summary {
+	return summary.Summary{
+		UserID:     dbSmm.UserID,
+		UserName:   dbSmm.UserName,
+		TotalCount: dbSmm.TotalCount,
+		TotalCost:  dbSmm.TotalCost,
+	}
+}
+
+func toCoreSummarySlice(dbSmms []dbSummary) []summary.Summary {
+	smms := make([]summary.Summary, len(dbSmms))
+	for i, dbSmm := range dbSmms {
+		smms[i] = toCoreSummary(dbSmm)
+	}
+	return smms
+}
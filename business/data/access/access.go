@@ -0,0 +1,209 @@
+// Package access provides support for describing the rows of data a caller
+// is allowed to see.
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Set of operators a condition can use.
+const (
+	OpEQ  = "="
+	OpNEQ = "!="
+	OpLT  = "<"
+	OpLTE = "<="
+	OpGT  = ">"
+	OpGTE = ">="
+)
+
+var operators = map[string]string{
+	OpEQ:  "=",
+	OpNEQ: "<>",
+	OpLT:  "<",
+	OpLTE: "<=",
+	OpGT:  ">",
+	OpGTE: ">=",
+}
+
+// =============================================================================
+
+// Condition represents the comparison of a field against a value.
+type Condition struct {
+	Field string
+	Op    string
+	Value any
+}
+
+// Filter represents the set of rows that can be seen. A row is visible when it
+// matches all the conditions of any one of the sets. The zero value allows no
+// rows, so a filter that was never set fails closed. Use All to allow every
+// row.
+type Filter struct {
+	all   bool
+	anyOf [][]Condition
+}
+
+// All constructs a filter that allows every row.
+func All() Filter {
+	return Filter{
+		all: true,
+	}
+}
+
+// None constructs a filter that allows no rows.
+func None() Filter {
+	return Filter{}
+}
+
+// AnyOf constructs a filter that allows the rows matching all the conditions
+// of any of the provided sets. A set with no conditions allows every row.
+func AnyOf(sets ...[]Condition) Filter {
+	for _, set := range sets {
+		if len(set) == 0 {
+			return All()
+		}
+	}
+
+	return Filter{
+		anyOf: sets,
+	}
+}
+
+// Restricted reports whether the filter excludes any rows.
+func (f Filter) Restricted() bool {
+	return !f.all
+}
+
+// Where constructs the SQL condition for the filter using the map of field
+// names to column names. The values are added to data as named parameters.
+// An empty string is returned when the filter is not restricted.
+func (f Filter) Where(columns map[string]string, data map[string]any) (string, error) {
+	if f.all {
+		return "", nil
+	}
+
+	if len(f.anyOf) == 0 {
+		return "FALSE", nil
+	}
+
+	var n int
+	ors := make([]string, len(f.anyOf))
+	for i, set := range f.anyOf {
+		ands := make([]string, len(set))
+		for j, cond := range set {
+			column, exists := columns[cond.Field]
+			if !exists {
+				return "", fmt.Errorf("field %q does not exist", cond.Field)
+			}
+
+			op, exists := operators[cond.Op]
+			if !exists {
+				return "", fmt.Errorf("operator %q does not exist", cond.Op)
+			}
+
+			name := fmt.Sprintf("access_%d", n)
+			n++
+
+			data[name] = cond.Value
+			ands[j] = fmt.Sprintf("%s %s :%s", column, op, name)
+		}
+		ors[i] = "(" + strings.Join(ands, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", nil
+}
+
+// Match reports whether a row with the specified field values is allowed by
+// the filter. It's used to filter data that isn't read from a store, like the
+// events sent to a subscriber. A field missing from the row doesn't match.
+func (f Filter) Match(row map[string]any) bool {
+	if f.all {
+		return true
+	}
+
+	for _, set := range f.anyOf {
+		if matchAll(set, row) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAll reports whether the row matches all the conditions.
+func matchAll(set []Condition, row map[string]any) bool {
+	for _, cond := range set {
+		value, exists := row[cond.Field]
+		if !exists {
+			return false
+		}
+
+		n, ok := compare(value, cond.Value)
+		if !ok {
+			return false
+		}
+
+		var match bool
+		switch cond.Op {
+		case OpEQ:
+			match = n == 0
+		case OpNEQ:
+			match = n != 0
+		case OpLT:
+			match = n < 0
+		case OpLTE:
+			match = n <= 0
+		case OpGT:
+			match = n > 0
+		case OpGTE:
+			match = n >= 0
+		}
+
+		if !match {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compare compares two values as numbers when both are numbers and as
+// strings otherwise.
+func compare(a any, b any) (int, bool) {
+	fa, aok := number(a)
+	fb, bok := number(b)
+	if aok && bok {
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	if aok != bok {
+		return 0, false
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+}
+
+// number converts a numeric value to a float.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	return 0, false
+}
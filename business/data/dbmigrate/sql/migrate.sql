@@ -34,4 +34,43 @@ SELECT u.user_id AS user_id,
     SUM(p.cost) AS total_cost
 FROM users AS u
     JOIN products AS p ON p.user_id = u.user_id
-GROUP BY u.user_id
\ No newline at end of file
+GROUP BY u.user_id
+-- Version: 1.04
+-- Description: Create table sessions
+CREATE TABLE sessions (
+    session_id TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    roles TEXT [] NOT NULL,
+    csrf_token TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_expires TIMESTAMP NOT NULL,
+    PRIMARY KEY (session_id)
+);
+-- Version: 1.05
+-- Description: Create table rate_limits
+CREATE TABLE rate_limits (
+    bucket_key TEXT NOT NULL,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+    PRIMARY KEY (bucket_key)
+);
+-- Version: 1.06
+-- Description: Create table idempotency_keys
+CREATE TABLE idempotency_keys (
+    idempotency_key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    completed BOOLEAN NOT NULL,
+    status_code INT NOT NULL,
+    header JSONB NOT NULL,
+    body BYTEA NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_expires TIMESTAMP NOT NULL,
+    PRIMARY KEY (idempotency_key)
+);
+-- Version: 1.07
+-- Description: Add version column to users
+ALTER TABLE users ADD COLUMN version INT NOT NULL DEFAULT 1;
+-- Version: 1.08
+-- Description: Add version column to products
+ALTER TABLE products ADD COLUMN version INT NOT NULL DEFAULT 1;
@@ -42,8 +42,12 @@ func NewBy(field string, direction string) By {
 // Parse constructs a order.By value by parsing a string in the form
 // of "field,direction".
 func Parse(r *http.Request, defaultOrder By) (By, error) {
-	v := r.URL.Query().Get("orderBy")
+	return ParseValue(r.URL.Query().Get("orderBy"), defaultOrder)
+}
 
+// ParseValue constructs a order.By value from a string in the form of
+// "field,direction", for the orderings that aren't sent in a query string.
+func ParseValue(v string, defaultOrder By) (By, error) {
 	if v == "" {
 		return defaultOrder, nil
 	}
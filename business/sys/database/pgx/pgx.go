@@ -14,6 +14,7 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -29,6 +30,13 @@ var (
 	ErrUndefinedTable    = errors.New("undefined table")
 )
 
+// Redacted is implemented by the data of queries whose values must never be
+// written to the logs or traces, like tokens. Those queries are reported with
+// the named parameters in place of the values.
+type Redacted interface {
+	Redacted()
+}
+
 // Config is the required properties to use the database.
 type Config struct {
 	User         string
@@ -76,6 +84,9 @@ func Open(cfg Config) (*sqlx.DB, error) {
 // StatusCheck returns nil if it can successfully talk to the database. It
 // returns a non-nil error otherwise.
 func StatusCheck(ctx context.Context, db *sqlx.DB) error {
+	ctx, span := web.AddSpan(ctx, "business.sys.database.statuscheck")
+	defer span.End()
+
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Second)
@@ -109,8 +120,11 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn func(*sqlx.Tx) error) error {
 	traceID := web.GetTraceID(ctx)
 
+	ctx, span := web.AddSpan(ctx, "business.sys.database.tran")
+	defer span.End()
+
 	log.Infow("begin tran")
-	tx, err := db.Beginx()
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tran: %w", err)
 	}
@@ -159,6 +173,9 @@ func NamedExecContext(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 		log.WithOptions(zap.AddCallerSkip(2)).Infow("database.NamedExecContext", "trace_id", web.GetTraceID(ctx), "query", q)
 	}
 
+	ctx, span := web.AddSpan(ctx, "business.sys.database.exec", attribute.String("query", q))
+	defer span.End()
+
 	if _, err := sqlx.NamedExecContext(ctx, db, query, data); err != nil {
 		if pqerr, ok := err.(*pgconn.PgError); ok {
 			switch pqerr.Code {
@@ -199,6 +216,9 @@ func namedQuerySlice[T any](ctx context.Context, log *zap.SugaredLogger, db sqlx
 
 	log.WithOptions(zap.AddCallerSkip(3)).Infow("database.NamedQuerySlice", "trace_id", web.GetTraceID(ctx), "query", q)
 
+	ctx, span := web.AddSpan(ctx, "business.sys.database.queryslice", attribute.String("query", q))
+	defer span.End()
+
 	var rows *sqlx.Rows
 	var err error
 
@@ -268,6 +288,9 @@ func namedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 
 	log.WithOptions(zap.AddCallerSkip(3)).Infow("database.NamedQueryStruct", "trace_id", web.GetTraceID(ctx), "query", q)
 
+	ctx, span := web.AddSpan(ctx, "business.sys.database.query", attribute.String("query", q))
+	defer span.End()
+
 	var rows *sqlx.Rows
 	var err error
 
@@ -312,7 +335,12 @@ func namedQueryStruct(ctx context.Context, log *zap.SugaredLogger, db sqlx.ExtCo
 }
 
 // queryString provides a pretty print version of the query and parameters.
+// The parameters are left out when the data is Redacted.
 func queryString(query string, args any) string {
+	if _, ok := args.(Redacted); ok {
+		return compactQuery(query)
+	}
+
 	query, params, err := sqlx.Named(query, args)
 	if err != nil {
 		return err.Error()
@@ -331,6 +359,11 @@ func queryString(query string, args any) string {
 		query = strings.Replace(query, "?", value, 1)
 	}
 
+	return compactQuery(query)
+}
+
+// compactQuery puts the query on a single line for the logs.
+func compactQuery(query string) string {
 	query = strings.ReplaceAll(query, "\t", "")
 	query = strings.ReplaceAll(query, "\n", " ")
 
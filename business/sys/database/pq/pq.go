@@ -78,6 +78,9 @@ func Open(cfg Config) (*sqlx.DB, error) {
 // StatusCheck returns nil if it can successfully talk to the database. It
 // returns a non-nil error otherwise.
 func StatusCheck(ctx context.Context, db *sqlx.DB) error {
+	ctx, span := web.AddSpan(ctx, "business.sys.database.statuscheck")
+	defer span.End()
+
 	if _, ok := ctx.Deadline(); !ok {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Second)
@@ -111,8 +114,11 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 func WithinTran(ctx context.Context, log *zap.SugaredLogger, db *sqlx.DB, fn func(*sqlx.Tx) error) error {
 	traceID := web.GetTraceID(ctx)
 
+	ctx, span := web.AddSpan(ctx, "business.sys.database.tran")
+	defer span.End()
+
 	log.Infow("begin tran")
-	tx, err := db.Beginx()
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("begin tran: %w", err)
 	}
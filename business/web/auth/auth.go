@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/data/access"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/open-policy-agent/opa/ast"
 	"github.com/open-policy-agent/opa/rego"
 	"go.uber.org/zap"
 	"strings"
-	"sync"
+	"time"
 )
 
 var ErrForbidden = errors.New("attempted action is not allowed")
@@ -27,11 +30,13 @@ type KeyLookup interface {
 	PublicKey(kid string) (key string, err error)
 }
 
-// Config represents information required to initialize auth.
+// Config represents information required to initialize auth. Services are
+// the internal services allowed to authenticate with a client certificate.
 type Config struct {
 	Log       *zap.SugaredLogger
 	KeyLookup KeyLookup
 	Issuer    string
+	Services  []ServiceIdentity
 }
 
 // Auth is used to authenticate clients. It can generate a token for a
@@ -42,8 +47,7 @@ type Auth struct {
 	method    jwt.SigningMethod
 	parser    *jwt.Parser
 	issuer    string
-	mu        sync.RWMutex
-	cache     map[string]string
+	services  map[string]ServiceIdentity
 }
 
 // New creates an Auth to support authentication/authorization.
@@ -55,7 +59,11 @@ func New(cfg Config) (*Auth, error) {
 		method:    jwt.GetSigningMethod(jwt.SigningMethodRS256.Name),
 		parser:    jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Name})),
 		issuer:    cfg.Issuer,
-		cache:     make(map[string]string),
+		services:  make(map[string]ServiceIdentity, len(cfg.Services)),
+	}
+
+	for _, si := range cfg.Services {
+		a.services[si.Name] = si
 	}
 
 	return &a, nil
@@ -85,28 +93,34 @@ func (a *Auth) GenerateToken(kid string, claims Claims) (string, error) {
 }
 
 // Authenticate processes the token to validate the sender's token is valid.
+// The errors for a token that can't be parsed or has expired wrap
+// ErrTokenMalformed and ErrTokenExpired.
 func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, error) {
+	if bearerToken == "" {
+		return Claims{}, errors.New("no authorization header provided")
+	}
+
 	parts := strings.Split(bearerToken, " ")
 	if len(parts) != 2 || parts[0] != "Bearer" {
-		return Claims{}, errors.New("expected authorization header format: Bearer <token>")
+		return Claims{}, fmt.Errorf("expected authorization header format: Bearer <token>: %w", ErrTokenMalformed)
 	}
 
 	var claims Claims
 	token, _, err := a.parser.ParseUnverified(parts[1], &claims)
 	if err != nil {
-		return Claims{}, fmt.Errorf("error parsing token: %w", err)
+		return Claims{}, fmt.Errorf("error parsing token: %w: %w", ErrTokenMalformed, err)
 	}
 
 	// Perform an extra level of authentication verification with OPA.
 
 	kidRaw, exists := token.Header["kid"]
 	if !exists {
-		return Claims{}, fmt.Errorf("kid missing from header: %w", err)
+		return Claims{}, fmt.Errorf("kid missing from header: %w", ErrTokenMalformed)
 	}
 
 	kid, ok := kidRaw.(string)
 	if !ok {
-		return Claims{}, fmt.Errorf("kid malformed: %w", err)
+		return Claims{}, fmt.Errorf("kid malformed: %w", ErrTokenMalformed)
 	}
 
 	pem, err := a.publicKeyLookup(kid)
@@ -121,6 +135,9 @@ func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, er
 	}
 
 	if err := a.opaPolicyEvaluation(ctx, opaAuthentication, RuleAuthenticate, input); err != nil {
+		if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
+			return Claims{}, fmt.Errorf("authentication.rego failed : %w: %w", ErrTokenExpired, err)
+		}
 		return Claims{}, fmt.Errorf("authentication.rego failed : %w", err)
 	}
 
@@ -129,12 +146,13 @@ func (a *Auth) Authenticate(ctx context.Context, bearerToken string) (Claims, er
 
 // Authorize attempts to authorize the user with the provided input roles, if
 // none of the input roles are within the user's claims, we return an error
-// otherwise the user is authorized.
-func (a *Auth) Authorize(ctx context.Context, claims Claims, rule string) error {
+// otherwise the user is authorized. The userID is the owner of the resource
+// being accessed and is compared against the subject by ownership rules.
+func (a *Auth) Authorize(ctx context.Context, claims Claims, userID uuid.UUID, rule string) error {
 	input := map[string]any{
 		"Roles":   claims.Roles,
 		"Subject": claims.Subject,
-		"UserID":  claims.Subject,
+		"UserID":  userID.String(),
 	}
 
 	if err := a.opaPolicyEvaluation(ctx, opaAuthorization, rule, input); err != nil {
@@ -144,33 +162,79 @@ func (a *Auth) Authorize(ctx context.Context, claims Claims, rule string) error
 	return nil
 }
 
-// =============================================================================
+// AuthorizeRoles attempts to authorize the user by the roles in the claims
+// alone, for the rules that don't involve the owner of a resource. No owner
+// is given to the policy, so ownership rules fail.
+func (a *Auth) AuthorizeRoles(ctx context.Context, claims Claims, rule string) error {
+	input := map[string]any{
+		"Roles":   claims.Roles,
+		"Subject": claims.Subject,
+	}
 
-// publicKeyLookup performs a lookup for the public pem for the specified kid.
-func (a *Auth) publicKeyLookup(kid string) (string, error) {
-	pem, err := func() (string, error) {
-		a.mu.RLock()
-		defer a.mu.RUnlock()
+	if err := a.opaPolicyEvaluation(ctx, opaAuthorization, rule, input); err != nil {
+		return fmt.Errorf("rego evaluation failed : %w", err)
+	}
+
+	return nil
+}
+
+// QueryFilter runs a partial evaluation of the specified list rule for the
+// claims. The row being listed is unknown to OPA, so the conditions left over
+// from the evaluation describe the rows the claims are allowed to see. These
+// conditions are returned as a filter for the stores to apply.
+func (a *Auth) QueryFilter(ctx context.Context, claims Claims, rule string) (access.Filter, error) {
+	input := map[string]any{
+		"Roles":   claims.Roles,
+		"Subject": claims.Subject,
+	}
+
+	query := fmt.Sprintf("data.%s.%s == true", opaPackage, rule)
+
+	pq, err := rego.New(
+		rego.Query(query),
+		rego.Module("policy.rego", opaAuthorization),
+		rego.Unknowns([]string{opaRowRef}),
+		rego.Input(input),
+	).Partial(ctx)
+	if err != nil {
+		return access.Filter{}, fmt.Errorf("partial evaluation: %w", err)
+	}
+
+	if len(pq.Support) > 0 {
+		return access.Filter{}, fmt.Errorf("rule %q requires support modules which can't be translated", rule)
+	}
 
-		pem, exists := a.cache[kid]
-		if !exists {
-			return "", errors.New("not found")
+	if len(pq.Queries) == 0 {
+		return access.None(), nil
+	}
+
+	sets := make([][]access.Condition, len(pq.Queries))
+	for i, body := range pq.Queries {
+		conds := make([]access.Condition, len(body))
+		for j, expr := range body {
+			cond, err := toCondition(expr)
+			if err != nil {
+				return access.Filter{}, fmt.Errorf("translating %q: %w", expr, err)
+			}
+			conds[j] = cond
 		}
-		return pem, nil
-	}()
-	if err == nil {
-		return pem, nil
+		sets[i] = conds
 	}
 
-	pem, err = a.keyLookup.PublicKey(kid)
+	return access.AnyOf(sets...), nil
+}
+
+// =============================================================================
+
+// publicKeyLookup performs a lookup for the public pem for the specified kid.
+// Keys aren't cached here, so a key lookup that caches keys decides how long
+// they are kept.
+func (a *Auth) publicKeyLookup(kid string) (string, error) {
+	pem, err := a.keyLookup.PublicKey(kid)
 	if err != nil {
 		return "", fmt.Errorf("fetching public key: %w", err)
 	}
 
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	a.cache[kid] = pem
-
 	return pem, nil
 }
 
@@ -203,3 +267,58 @@ func (a *Auth) opaPolicyEvaluation(ctx context.Context, opaPolicy string, rule s
 
 	return nil
 }
+
+// residualOps maps the builtins allowed in a residual expression to the
+// filter operator and the operator to use when the operands are swapped.
+var residualOps = map[string][2]string{
+	"eq":    {access.OpEQ, access.OpEQ},
+	"equal": {access.OpEQ, access.OpEQ},
+	"neq":   {access.OpNEQ, access.OpNEQ},
+	"lt":    {access.OpLT, access.OpGT},
+	"lte":   {access.OpLTE, access.OpGTE},
+	"gt":    {access.OpGT, access.OpLT},
+	"gte":   {access.OpGTE, access.OpLTE},
+}
+
+// toCondition translates a residual expression in the form of
+// `input.Row.<Field> <op> <value>` into a filter condition.
+func toCondition(expr *ast.Expr) (access.Condition, error) {
+	if expr.Negated || !expr.IsCall() || len(expr.Operands()) != 2 {
+		return access.Condition{}, errors.New("unsupported expression")
+	}
+
+	ops, exists := residualOps[expr.Operator().String()]
+	if !exists {
+		return access.Condition{}, fmt.Errorf("unsupported operator %s", expr.Operator())
+	}
+
+	field, value := expr.Operand(0), expr.Operand(1)
+	op := ops[0]
+	if _, ok := field.Value.(ast.Ref); !ok {
+		field, value = value, field
+		op = ops[1]
+	}
+
+	ref, ok := field.Value.(ast.Ref)
+	if !ok || len(ref) != 3 || !ref.HasPrefix(ast.MustParseRef(opaRowRef)) {
+		return access.Condition{}, errors.New("expected a reference to a row field")
+	}
+
+	name, ok := ref[2].Value.(ast.String)
+	if !ok {
+		return access.Condition{}, errors.New("row field must be a string")
+	}
+
+	v, err := ast.JSON(value.Value)
+	if err != nil {
+		return access.Condition{}, fmt.Errorf("value must be a scalar: %w", err)
+	}
+
+	cond := access.Condition{
+		Field: string(name),
+		Op:    op,
+		Value: v,
+	}
+
+	return cond, nil
+}
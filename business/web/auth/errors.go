@@ -5,16 +5,40 @@ import (
 	"fmt"
 )
 
+// Set of kinds of auth failures. The kind decides the status and the
+// challenge sent to the client, the message is only logged.
+const (
+	KindUnauthenticated = "unauthenticated"
+	KindForbidden       = "forbidden"
+	KindExpired         = "expired"
+	KindMalformed       = "malformed"
+)
+
+// Set of error variables for the token failures that are told apart.
+var (
+	ErrTokenExpired   = errors.New("token has expired")
+	ErrTokenMalformed = errors.New("token is malformed")
+)
+
 // AuthError is used to pass an error during the request through the
 // application with auth specific context.
 type AuthError struct {
-	msg string
+	kind string
+	msg  string
 }
 
-// NewAuthError creates an AuthError for the provided message.
+// NewAuthError creates an AuthError for the provided message. The caller
+// couldn't be authenticated.
 func NewAuthError(format string, args ...any) error {
+	return NewAuthErrorKind(KindUnauthenticated, format, args...)
+}
+
+// NewAuthErrorKind creates an AuthError of the specified kind for the
+// provided message.
+func NewAuthErrorKind(kind string, format string, args ...any) error {
 	return &AuthError{
-		msg: fmt.Sprintf(format, args...),
+		kind: kind,
+		msg:  fmt.Sprintf(format, args...),
 	}
 }
 
@@ -24,8 +48,45 @@ func (ae *AuthError) Error() string {
 	return ae.msg
 }
 
+// Kind returns the kind of the failure.
+func (ae *AuthError) Kind() string {
+	return ae.kind
+}
+
+// Unwrap returns ErrForbidden for the forbidden kind, so the error can be
+// matched against it.
+func (ae *AuthError) Unwrap() error {
+	if ae.kind == KindForbidden {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
 // IsAuthError checks if an error of type AuthError exists.
 func IsAuthError(err error) bool {
 	var ae *AuthError
 	return errors.As(err, &ae)
 }
+
+// GetAuthError returns a copy of the AuthError pointer.
+func GetAuthError(err error) *AuthError {
+	var ae *AuthError
+	if !errors.As(err, &ae) {
+		return nil
+	}
+	return ae
+}
+
+// TokenErrorKind returns the kind of failure for an error returned by
+// Authenticate.
+func TokenErrorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		return KindExpired
+	case errors.Is(err, ErrTokenMalformed):
+		return KindMalformed
+	}
+
+	return KindUnauthenticated
+}
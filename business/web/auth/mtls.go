@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/farmani/service/business/core/user"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ServiceIdentity represents an internal service allowed to call the api
+// with a client certificate, and the roles it's given. Name is matched
+// against the URI, like a SPIFFE ID, and DNS names of the certificate, then
+// its subject common name.
+type ServiceIdentity struct {
+	Name  string
+	Roles []user.Role
+}
+
+// AuthenticateCertificate maps a client certificate to the service identity
+// it belongs to and returns the claims for it. The certificate must have been
+// verified against the trusted CAs by the TLS handshake already. The subject
+// of the claims is the name of the identity.
+func (a *Auth) AuthenticateCertificate(cert *x509.Certificate) (Claims, error) {
+	if cert == nil {
+		return Claims{}, errors.New("no client certificate provided")
+	}
+
+	names := certificateNames(cert)
+
+	for _, name := range names {
+		si, exists := a.services[name]
+		if !exists {
+			continue
+		}
+
+		claims := Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   si.Name,
+				IssuedAt:  jwt.NewNumericDate(cert.NotBefore),
+				ExpiresAt: jwt.NewNumericDate(cert.NotAfter),
+			},
+			Roles: si.Roles,
+		}
+
+		return claims, nil
+	}
+
+	return Claims{}, fmt.Errorf("certificate names %v don't belong to a service identity", names)
+}
+
+// certificateNames returns the names identifying the certificate, in the
+// order they are matched.
+func certificateNames(cert *x509.Certificate) []string {
+	var names []string
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+
+	names = append(names, cert.DNSNames...)
+
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+
+	return names
+}
+
+// ParseServiceIdentities parses service identities in the form of
+// "name=ROLE,ROLE", like "spiffe://cluster.local/ns/sales-system/sa/billing=ADMIN".
+func ParseServiceIdentities(values []string) ([]ServiceIdentity, error) {
+	identities := make([]ServiceIdentity, len(values))
+	for i, value := range values {
+		name, roles, ok := strings.Cut(value, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("service identity %q is not in the form of name=ROLE,ROLE", value)
+		}
+
+		si := ServiceIdentity{
+			Name: name,
+		}
+
+		for _, role := range strings.Split(roles, ",") {
+			r, err := user.ParseRole(strings.TrimSpace(role))
+			if err != nil {
+				return nil, fmt.Errorf("service identity %q: %w", value, err)
+			}
+			si.Roles = append(si.Roles, r)
+		}
+
+		identities[i] = si
+	}
+
+	return identities, nil
+}
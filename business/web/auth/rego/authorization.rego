@@ -37,3 +37,18 @@ ruleAdminOrSubject {
 	count(input_user) > 0
 	input.UserID == input.Subject
 }
+
+default ruleListAdminOrSubject = false
+
+ruleListAdminOrSubject {
+	claim_roles := {role | role := input.Roles[_]}
+	input_admin := {roleAdmin} & claim_roles
+	count(input_admin) > 0
+}
+
+ruleListAdminOrSubject {
+	claim_roles := {role | role := input.Roles[_]}
+	input_user := {roleUser} & claim_roles
+	count(input_user) > 0
+	input.Row.UserID == input.Subject
+}
@@ -11,11 +11,22 @@ const (
 	RuleAdminOrSubject = "ruleAdminOrSubject"
 )
 
+// These are the rules used to filter the rows of a list. They are partially
+// evaluated with the row unknown to produce the conditions for a query.
+const (
+	RuleListAdminOrSubject = "ruleListAdminOrSubject"
+)
+
 // Package name of our rego code.
 const (
 	opaPackage string = "farmani.rego"
 )
 
+// Reference to the row in the input of list rules.
+const (
+	opaRowRef string = "input.Row"
+)
+
 // Core OPA policies.
 var (
 	//go:embed rego/authentication.rego
@@ -0,0 +1,160 @@
+// Package idempotency provides support for safely retrying unsafe requests
+// using the Idempotency-Key header. The response of the first request made
+// with a key is stored and replayed for the retries.
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Set of error variables for idempotent requests.
+var (
+	ErrNotFound   = errors.New("idempotency key not found")
+	ErrInProgress = errors.New("a request with the idempotency key is still in progress")
+	ErrMismatch   = errors.New("idempotency key was used for a different request")
+	ErrInvalidKey = errors.New("idempotency key must be between 1 and 255 characters")
+)
+
+// Set of headers used by the idempotency support.
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Limits on the idempotent requests. Responses larger than MaxResponseSize
+// aren't stored, so retrying such a request runs it again.
+const (
+	MaxKeyLength    = 255
+	MaxResponseSize = 1 << 20
+)
+
+// =============================================================================
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve idempotent requests. Create must only insert the record when no
+// unexpired record exists for the key and report whether it did, so two
+// concurrent requests can't both claim the key.
+type Storer interface {
+	Create(ctx context.Context, rec Record, now time.Time) (bool, error)
+	Complete(ctx context.Context, rec Record) error
+	Delete(ctx context.Context, key string) error
+	QueryByKey(ctx context.Context, key string) (Record, error)
+}
+
+// Config represents information required to initialize idempotency support.
+// TTL is how long a response is kept for replay.
+type Config struct {
+	Storer Storer
+	TTL    time.Duration
+}
+
+// Manager manages the set of APIs for idempotent requests.
+type Manager struct {
+	storer Storer
+	ttl    time.Duration
+}
+
+// NewManager constructs a manager for idempotent request api access.
+func NewManager(cfg Config) *Manager {
+	return &Manager{
+		storer: cfg.Storer,
+		ttl:    cfg.TTL,
+	}
+}
+
+// Begin claims the key for a new request with the specified fingerprint. When
+// the key was already used for the same request, its stored response is
+// returned with true so it can be replayed. ErrInProgress is returned while
+// the first request hasn't completed and ErrMismatch when the key was used
+// for a different request.
+func (m *Manager) Begin(ctx context.Context, key string, fingerprint string, now time.Time) (Response, bool, error) {
+	rec := Record{
+		Key:         key,
+		Fingerprint: fingerprint,
+		DateCreated: now,
+		DateExpires: now.Add(m.ttl),
+	}
+
+	created, err := m.storer.Create(ctx, rec, now)
+	if err != nil {
+		return Response{}, false, fmt.Errorf("create: %w", err)
+	}
+
+	if created {
+		return Response{}, false, nil
+	}
+
+	stored, err := m.storer.QueryByKey(ctx, key)
+	if err != nil {
+		return Response{}, false, fmt.Errorf("query: key[%s]: %w", key, err)
+	}
+
+	switch {
+	case stored.Fingerprint != fingerprint:
+		return Response{}, false, ErrMismatch
+	case !stored.Completed:
+		return Response{}, false, ErrInProgress
+	}
+
+	resp := Response{
+		StatusCode: stored.StatusCode,
+		Header:     stored.Header,
+		Body:       stored.Body,
+	}
+
+	return resp, true, nil
+}
+
+// Complete stores the response of the request made with the key for replay.
+func (m *Manager) Complete(ctx context.Context, key string, resp Response) error {
+	rec := Record{
+		Key:        key,
+		Completed:  true,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       resp.Body,
+	}
+
+	if err := m.storer.Complete(ctx, rec); err != nil {
+		return fmt.Errorf("complete: key[%s]: %w", key, err)
+	}
+
+	return nil
+}
+
+// Release gives up the key after a request that failed, so the client can
+// retry it.
+func (m *Manager) Release(ctx context.Context, key string) error {
+	if err := m.storer.Delete(ctx, key); err != nil {
+		return fmt.Errorf("delete: key[%s]: %w", key, err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Fingerprint identifies a request by its method, path and body, so a key
+// reused for a different request can be detected.
+func Fingerprint(method string, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ValidKey checks the idempotency key sent by a client.
+func ValidKey(key string) error {
+	if key == "" || len(key) > MaxKeyLength {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package idempotency
+
+import (
+	"net/http"
+	"time"
+)
+
+// Record represents a request made with an idempotency key and, once it has
+// completed, the response that was sent for it.
+type Record struct {
+	Key         string
+	Fingerprint string
+	Completed   bool
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	DateCreated time.Time
+	DateExpires time.Time
+}
+
+// Response contains the response of a completed request that is stored for
+// replay.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
@@ -0,0 +1,171 @@
+// Package idempotencydb contains the idempotent requests kept in the database
+// so a retry is recognized by every pod of the service.
+package idempotencydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	database "github.com/farmani/service/business/sys/database/pgx"
+	"github.com/farmani/service/business/web/idempotency"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for idempotent request database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts the record unless an unexpired record exists for the key. An
+// expired record is replaced. The work is done by a single upsert so
+// concurrent requests with the same key can't both claim it.
+func (s *Store) Create(ctx context.Context, rec idempotency.Record, now time.Time) (bool, error) {
+	dbRec, err := toDBRecord(rec)
+	if err != nil {
+		return false, err
+	}
+
+	data := struct {
+		dbRecord
+		Now time.Time `db:"now"`
+	}{
+		dbRecord: dbRec,
+		Now:      now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO idempotency_keys AS ik
+		(idempotency_key, fingerprint, completed, status_code, header, body, date_created, date_expires)
+	VALUES
+		(:idempotency_key, :fingerprint, :completed, :status_code, :header, :body, :date_created, :date_expires)
+	ON CONFLICT (idempotency_key) DO UPDATE SET
+		fingerprint = EXCLUDED.fingerprint,
+		completed = EXCLUDED.completed,
+		status_code = EXCLUDED.status_code,
+		header = EXCLUDED.header,
+		body = EXCLUDED.body,
+		date_created = EXCLUDED.date_created,
+		date_expires = EXCLUDED.date_expires
+	WHERE
+		ik.date_expires < :now
+	RETURNING
+		idempotency_key`
+
+	var dbKey struct {
+		Key string `db:"idempotency_key"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbKey); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("namedquerystruct: %w", err)
+	}
+
+	return true, nil
+}
+
+// Complete stores the response of the request in the database.
+func (s *Store) Complete(ctx context.Context, rec idempotency.Record) error {
+	dbRec, err := toDBRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+	UPDATE
+		idempotency_keys
+	SET
+		"completed" = :completed,
+		"status_code" = :status_code,
+		"header" = :header,
+		"body" = :body
+	WHERE
+		idempotency_key = :idempotency_key`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, dbRec); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the record for the key from the database.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	data := struct {
+		Key string `db:"idempotency_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `
+	DELETE FROM
+		idempotency_keys
+	WHERE
+		idempotency_key = :idempotency_key`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByKey gets the record for the key from the database.
+func (s *Store) QueryByKey(ctx context.Context, key string) (idempotency.Record, error) {
+	data := struct {
+		Key string `db:"idempotency_key"`
+	}{
+		Key: key,
+	}
+
+	const q = `
+	SELECT
+		idempotency_key, fingerprint, completed, status_code, header, body, date_created, date_expires
+	FROM
+		idempotency_keys
+	WHERE
+		idempotency_key = :idempotency_key`
+
+	var dbRec dbRecord
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbRec); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return idempotency.Record{}, fmt.Errorf("namedquerystruct: %w", idempotency.ErrNotFound)
+		}
+		return idempotency.Record{}, fmt.Errorf("namedquerystruct: %w", err)
+	}
+
+	return toCoreRecord(dbRec)
+}
+
+// Purge removes the records that expired before the specified time.
+func (s *Store) Purge(ctx context.Context, before time.Time) error {
+	data := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before.UTC(),
+	}
+
+	const q = `
+	DELETE FROM
+		idempotency_keys
+	WHERE
+		date_expires < :before`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package idempotencydb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/farmani/service/business/web/idempotency"
+)
+
+// dbRecord represent the structure we need for moving data
+// between the app and the database.
+type dbRecord struct {
+	Key         string    `db:"idempotency_key"`
+	Fingerprint string    `db:"fingerprint"`
+	Completed   bool      `db:"completed"`
+	StatusCode  int       `db:"status_code"`
+	Header      string    `db:"header"`
+	Body        []byte    `db:"body"`
+	DateCreated time.Time `db:"date_created"`
+	DateExpires time.Time `db:"date_expires"`
+}
+
+func toDBRecord(rec idempotency.Record) (dbRecord, error) {
+	header := rec.Header
+	if header == nil {
+		header = http.Header{}
+	}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return dbRecord{}, fmt.Errorf("marshal header: %w", err)
+	}
+
+	body := rec.Body
+	if body == nil {
+		body = []byte{}
+	}
+
+	dbRec := dbRecord{
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		Completed:   rec.Completed,
+		StatusCode:  rec.StatusCode,
+		Header:      string(data),
+		Body:        body,
+		DateCreated: rec.DateCreated.UTC(),
+		DateExpires: rec.DateExpires.UTC(),
+	}
+
+	return dbRec, nil
+}
+
+func toCoreRecord(dbRec dbRecord) (idempotency.Record, error) {
+	var header http.Header
+	if err := json.Unmarshal([]byte(dbRec.Header), &header); err != nil {
+		return idempotency.Record{}, fmt.Errorf("unmarshal header: %w", err)
+	}
+
+	rec := idempotency.Record{
+		Key:         dbRec.Key,
+		Fingerprint: dbRec.Fingerprint,
+		Completed:   dbRec.Completed,
+		StatusCode:  dbRec.StatusCode,
+		Header:      header,
+		Body:        dbRec.Body,
+		DateCreated: dbRec.DateCreated.In(time.Local),
+		DateExpires: dbRec.DateExpires.In(time.Local),
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,251 @@
+// Package lifecycle provides support for shutting the service down without
+// dropping requests. On shutdown the service first reports it isn't ready so
+// the load balancer stops routing to it, waits for that to take effect, then
+// drains the requests in flight and runs the shutdown hooks.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Set of phases the service goes through.
+const (
+	PhaseRunning  = "running"
+	PhaseNotReady = "not ready"
+	PhaseDraining = "draining"
+	PhaseStopped  = "stopped"
+)
+
+// Hook represents the work to run on shutdown, like stopping a server or
+// closing a connection pool.
+type Hook struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// Status represents the progress of the shutdown.
+type Status struct {
+	Phase    string   `json:"phase"`
+	InFlight int64    `json:"inFlight"`
+	Pending  []string `json:"pending,omitempty"`
+	Done     []string `json:"done,omitempty"`
+}
+
+// Config represents information required to initialize the lifecycle.
+// DrainDelay is how long the service keeps serving after it reports it isn't
+// ready, so the endpoints of the load balancer are updated. Timeout bounds
+// the time the hooks have to finish.
+type Config struct {
+	Log        *zap.SugaredLogger
+	DrainDelay time.Duration
+	Timeout    time.Duration
+}
+
+// Manager manages the set of APIs for the lifecycle of the service.
+type Manager struct {
+	log        *zap.SugaredLogger
+	drainDelay time.Duration
+	timeout    time.Duration
+	inFlight   atomic.Int64
+	stopOnce   sync.Once
+	stopErr    error
+	bgCtx      context.Context
+	bgCancel   context.CancelFunc
+	bgWG       sync.WaitGroup
+
+	mu    sync.RWMutex
+	phase string
+	hooks []Hook
+	done  []string
+}
+
+// NewManager constructs a manager for the lifecycle of the service.
+func NewManager(cfg Config) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		log:        cfg.Log,
+		drainDelay: cfg.DrainDelay,
+		timeout:    cfg.Timeout,
+		bgCtx:      ctx,
+		bgCancel:   cancel,
+		phase:      PhaseRunning,
+	}
+}
+
+// Ready reports if the service should receive traffic.
+func (m *Manager) Ready() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.phase == PhaseRunning
+}
+
+// Status returns the progress of the shutdown.
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := Status{
+		Phase:    m.phase,
+		InFlight: m.inFlight.Load(),
+		Done:     append([]string(nil), m.done...),
+	}
+
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		s.Pending = append(s.Pending, m.hooks[i].Name)
+	}
+
+	return s
+}
+
+// Register adds a hook to run on shutdown. Hooks run in the reverse order
+// they were registered, like deferred calls, so a resource registered early
+// is released after the work registered later that uses it.
+func (m *Manager) Register(name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hooks = append(m.hooks, Hook{Name: name, Fn: fn})
+}
+
+// Track counts the requests in flight through the handler.
+func (m *Manager) Track(handler http.Handler) http.Handler {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+
+		handler.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(h)
+}
+
+// Go runs background work in a goroutine. The context passed to the work is
+// canceled by StopBackground.
+func (m *Manager) Go(fn func(ctx context.Context)) {
+	m.bgWG.Add(1)
+
+	go func() {
+		defer m.bgWG.Done()
+		fn(m.bgCtx)
+	}()
+}
+
+// StopBackground cancels the background work and waits for it to return.
+func (m *Manager) StopBackground(ctx context.Context) error {
+	m.bgCancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.bgWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for background work: %w", ctx.Err())
+	}
+}
+
+// Shutdown stops reporting the service as ready, waits for the drain delay
+// and then stops the service.
+func (m *Manager) Shutdown() error {
+	m.setPhase(PhaseNotReady)
+	m.log.Infow("shutdown", "status", "readiness disabled, waiting for endpoints to update", "delay", m.drainDelay, "inFlight", m.inFlight.Load())
+
+	time.Sleep(m.drainDelay)
+
+	return m.Stop()
+}
+
+// Stop runs the hooks while the requests in flight are drained. It can be
+// deferred to release the resources when the service fails to start, since
+// the hooks only run once.
+func (m *Manager) Stop() error {
+	m.stopOnce.Do(func() {
+		m.setPhase(PhaseDraining)
+
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+
+		done := make(chan struct{})
+		defer close(done)
+		go m.reportProgress(done)
+
+		var errs []error
+		for {
+			hook, ok := m.popHook()
+			if !ok {
+				break
+			}
+
+			m.log.Infow("shutdown", "status", "running hook", "hook", hook.Name, "inFlight", m.inFlight.Load())
+
+			if err := hook.Fn(ctx); err != nil {
+				m.log.Errorw("shutdown", "status", "hook failed", "hook", hook.Name, "ERROR", err)
+				errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+			}
+
+			m.mu.Lock()
+			m.done = append(m.done, hook.Name)
+			m.mu.Unlock()
+		}
+
+		m.setPhase(PhaseStopped)
+		m.stopErr = errors.Join(errs...)
+	})
+
+	return m.stopErr
+}
+
+// popHook removes the most recently registered hook.
+func (m *Manager) popHook() (Hook, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.hooks) == 0 {
+		return Hook{}, false
+	}
+
+	hook := m.hooks[len(m.hooks)-1]
+	m.hooks = m.hooks[:len(m.hooks)-1]
+
+	return hook, true
+}
+
+// reportProgress logs the number of requests in flight every second until
+// the hooks are done.
+func (m *Manager) reportProgress(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if n := m.inFlight.Load(); n > 0 {
+				m.log.Infow("shutdown", "status", "draining requests", "inFlight", n)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// setPhase moves the service to the phase.
+func (m *Manager) setPhase(phase string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.phase = phase
+}
@@ -3,8 +3,16 @@ package metrics
 
 import (
 	"context"
+	"database/sql"
 	"expvar"
+	"fmt"
+	"net/http"
 	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // This holds the single instance of the metrics value needed for
@@ -16,12 +24,18 @@ var m *metrics
 // =============================================================================
 
 // metrics represents the set of metrics we gather. These fields are
-// safe to be accessed concurrently thanks to expvar. No extra abstraction is required.
+// safe to be accessed concurrently thanks to expvar and the prometheus
+// collectors. No extra abstraction is required.
 type metrics struct {
 	goroutines *expvar.Int
 	requests   *expvar.Int
 	errors     *expvar.Int
 	panics     *expvar.Int
+
+	registry *prometheus.Registry
+	total    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
 }
 
 // init constructs the metrics value that will be used to capture metrics.
@@ -34,7 +48,46 @@ func init() {
 		requests:   expvar.NewInt("requests"),
 		errors:     expvar.NewInt("errors"),
 		panics:     expvar.NewInt("panics"),
+
+		registry: prometheus.NewRegistry(),
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of http requests handled.",
+		}, []string{"route", "method", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to handle http requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of http requests being handled.",
+		}, []string{"route", "method"}),
 	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.total,
+		m.duration,
+		m.inFlight,
+	)
+}
+
+// Handler returns the handler serving the prometheus metrics in the text
+// exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB adds the connection pool statistics of the database to the
+// prometheus metrics under the specified name.
+func RegisterDB(name string, db *sql.DB) error {
+	if err := m.registry.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		return fmt.Errorf("register db stats: %w", err)
+	}
+
+	return nil
 }
 
 // =============================================================================
@@ -77,3 +130,29 @@ func AddPanics(ctx context.Context) {
 		v.panics.Add(1)
 	}
 }
+
+// AddInFlight adjusts the number of requests being handled for the route.
+func AddInFlight(ctx context.Context, route string, method string, delta float64) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		v.inFlight.WithLabelValues(route, method).Add(delta)
+	}
+}
+
+// AddRequestDuration records a handled request for the route by the class of
+// the status code returned.
+func AddRequestDuration(ctx context.Context, route string, method string, statusCode int, d time.Duration) {
+	if v, ok := ctx.Value(key).(*metrics); ok {
+		status := statusClass(statusCode)
+		v.total.WithLabelValues(route, method, status).Inc()
+		v.duration.WithLabelValues(route, method, status).Observe(d.Seconds())
+	}
+}
+
+// statusClass converts a status code into its class, like 2xx.
+func statusClass(statusCode int) string {
+	if statusCode < 100 || statusCode > 599 {
+		return "unknown"
+	}
+
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
@@ -0,0 +1,126 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quota represents the number of requests allowed over a period of time. The
+// zero value places no limit on the requests.
+type Quota struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseQuota parses a quota in the form "limit/period" like "100/1m".
+func ParseQuota(s string) (Quota, error) {
+	limit, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Quota{}, fmt.Errorf("quota %q is not in the form limit/period", s)
+	}
+
+	l, err := strconv.Atoi(limit)
+	if err != nil || l < 0 {
+		return Quota{}, fmt.Errorf("quota %q has an invalid limit", s)
+	}
+
+	p, err := time.ParseDuration(period)
+	if err != nil || p <= 0 {
+		return Quota{}, fmt.Errorf("quota %q has an invalid period", s)
+	}
+
+	return Quota{Limit: l, Period: p}, nil
+}
+
+// ParseRouteQuotas parses a set of quotas for routes in the form
+// "METHOD /path=limit/period" like "POST /v1/sessions=10/1m".
+func ParseRouteQuotas(values []string) (map[string]Quota, error) {
+	quotas := make(map[string]Quota, len(values))
+	for _, value := range values {
+		route, quota, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("route quota %q is not in the form route=quota", value)
+		}
+
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok {
+			return nil, fmt.Errorf("route quota %q is missing the method", value)
+		}
+
+		q, err := ParseQuota(quota)
+		if err != nil {
+			return nil, err
+		}
+
+		quotas[routeKey(method, path)] = q
+	}
+
+	return quotas, nil
+}
+
+// Unlimited reports whether the quota places no limit on the requests.
+func (q Quota) Unlimited() bool {
+	return q.Limit == 0 || q.Period == 0
+}
+
+// Rate returns the number of tokens added to a bucket per second.
+func (q Quota) Rate() float64 {
+	return float64(q.Limit) / q.Period.Seconds()
+}
+
+// Refill returns the tokens in a bucket that last had the specified tokens at
+// the last time it was used. A bucket never holds more than the limit.
+func (q Quota) Refill(tokens float64, last time.Time, now time.Time) float64 {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	return math.Min(float64(q.Limit), tokens+elapsed*q.Rate())
+}
+
+// Policy returns the quota in the form used by the RateLimit-Policy header.
+func (q Quota) Policy() string {
+	return fmt.Sprintf("%d;w=%d", q.Limit, int(math.Ceil(q.Period.Seconds())))
+}
+
+// =============================================================================
+
+// Result represents the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// newResult constructs the result for a bucket left with the specified tokens.
+func newResult(q Quota, tokens float64, allowed bool) Result {
+	rate := q.Rate()
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     q.Limit,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(q.Limit) - tokens) / rate),
+	}
+
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / rate)
+	}
+
+	return res
+}
+
+// seconds converts a number of seconds into a duration.
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+
+	return time.Duration(s * float64(time.Second))
+}
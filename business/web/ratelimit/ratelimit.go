@@ -0,0 +1,109 @@
+// Package ratelimit provides support for limiting the rate of requests made by
+// clients using token buckets.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrRateLimited is returned when a client has used all of its quota.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// =============================================================================
+
+// Storer interface declares the behavior this package needs to keep the token
+// buckets. Take must refill the bucket for the key, remove a token when one is
+// available and return the tokens left as a single atomic operation.
+type Storer interface {
+	Take(ctx context.Context, key string, q Quota, now time.Time) (tokens float64, allowed bool, err error)
+}
+
+// Config represents information required to initialize rate limiting. The
+// Routes quotas are keyed by the method and route pattern and override the
+// Default quota.
+type Config struct {
+	Log            *zap.SugaredLogger
+	Storer         Storer
+	Default        Quota
+	Routes         map[string]Quota
+	TrustForwarded bool
+}
+
+// Limiter manages the set of APIs for rate limiting.
+type Limiter struct {
+	log            *zap.SugaredLogger
+	storer         Storer
+	def            Quota
+	routes         map[string]Quota
+	trustForwarded bool
+}
+
+// NewLimiter constructs a limiter for rate limiting api access.
+func NewLimiter(cfg Config) *Limiter {
+	return &Limiter{
+		log:            cfg.Log,
+		storer:         cfg.Storer,
+		def:            cfg.Default,
+		routes:         cfg.Routes,
+		trustForwarded: cfg.TrustForwarded,
+	}
+}
+
+// Quota returns the quota that applies to the method and route pattern.
+func (l *Limiter) Quota(method string, route string) Quota {
+	if q, exists := l.routes[routeKey(method, route)]; exists {
+		return q
+	}
+
+	return l.def
+}
+
+// Allow takes a token from the bucket for the key. When the store can't be
+// used the request is allowed, so an outage of the store doesn't take the
+// api down with it.
+func (l *Limiter) Allow(ctx context.Context, key string, q Quota, now time.Time) Result {
+	tokens, allowed, err := l.storer.Take(ctx, key, q, now)
+	if err != nil {
+		l.log.Errorw("ratelimit", "status", "unable to take token, allowing request", "key", key, "ERROR", err)
+		return Result{
+			Allowed:   true,
+			Limit:     q.Limit,
+			Remaining: q.Limit,
+		}
+	}
+
+	return newResult(q, tokens, allowed)
+}
+
+// ClientIP returns the ip address of the client making the request. The last
+// address of the X-Forwarded-For header is used when the service runs behind
+// a trusted proxy.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	if l.trustForwarded {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			ips := strings.Split(fwd[len(fwd)-1], ",")
+			if ip := strings.TrimSpace(ips[len(ips)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// routeKey constructs the key used to find the quota for a route.
+func routeKey(method string, route string) string {
+	return strings.ToUpper(method) + " " + route
+}
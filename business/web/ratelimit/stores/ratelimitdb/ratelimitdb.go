@@ -0,0 +1,92 @@
+// Package ratelimitdb contains the token buckets for rate limiting kept in the
+// database so the quotas are shared by every pod of the service.
+package ratelimitdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	database "github.com/farmani/service/business/sys/database/pgx"
+	"github.com/farmani/service/business/web/ratelimit"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for token bucket database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// refill is the expression for the tokens in a bucket after it is refilled
+// for the time passed since it was last used.
+const refill = `LEAST(:limit, rl.tokens + GREATEST(CAST(EXTRACT(EPOCH FROM (:now - rl.date_updated)) AS DOUBLE PRECISION), 0) * :rate)`
+
+// Take refills the bucket for the key and removes a token when one is
+// available. The work is done by a single upsert so concurrent requests from
+// different pods can't take the same token.
+func (s *Store) Take(ctx context.Context, key string, quota ratelimit.Quota, now time.Time) (float64, bool, error) {
+	data := struct {
+		Key   string    `db:"bucket_key"`
+		Limit float64   `db:"limit"`
+		Rate  float64   `db:"rate"`
+		Now   time.Time `db:"now"`
+	}{
+		Key:   key,
+		Limit: float64(quota.Limit),
+		Rate:  quota.Rate(),
+		Now:   now.UTC(),
+	}
+
+	const q = `
+	INSERT INTO rate_limits AS rl
+		(bucket_key, tokens, allowed, date_updated)
+	VALUES
+		(:bucket_key, CAST(:limit AS DOUBLE PRECISION) - 1, CAST(:limit AS DOUBLE PRECISION) >= 1, :now)
+	ON CONFLICT (bucket_key) DO UPDATE SET
+		tokens = ` + refill + ` - CASE WHEN ` + refill + ` >= 1 THEN 1 ELSE 0 END,
+		allowed = ` + refill + ` >= 1,
+		date_updated = GREATEST(rl.date_updated, :now)
+	RETURNING
+		tokens, allowed`
+
+	var dbBucket struct {
+		Tokens  float64 `db:"tokens"`
+		Allowed bool    `db:"allowed"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbBucket); err != nil {
+		return 0, false, fmt.Errorf("namedquerystruct: %w", err)
+	}
+
+	return dbBucket.Tokens, dbBucket.Allowed, nil
+}
+
+// Purge removes the buckets that haven't been used since the specified time.
+func (s *Store) Purge(ctx context.Context, before time.Time) error {
+	data := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before.UTC(),
+	}
+
+	const q = `
+	DELETE FROM
+		rate_limits
+	WHERE
+		date_updated < :before`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package ratelimitmem contains the token buckets for rate limiting kept in
+// memory. The buckets are local to the process, so the quotas apply per pod.
+package ratelimitmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/farmani/service/business/web/ratelimit"
+)
+
+// sweepInterval is how often buckets that have refilled are removed.
+const sweepInterval = time.Minute
+
+// bucket represents the state of a token bucket for a key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// Store manages the set of APIs for token bucket access in memory.
+type Store struct {
+	mu        sync.Mutex
+	store     map[string]bucket
+	lastSweep time.Time
+}
+
+// NewStore constructs the api for memory access.
+func NewStore() *Store {
+	return &Store{
+		store: make(map[string]bucket),
+	}
+}
+
+// Take refills the bucket for the key and removes a token when one is
+// available.
+func (s *Store) Take(ctx context.Context, key string, q ratelimit.Quota, now time.Time) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	tokens := float64(q.Limit)
+	if b, exists := s.store[key]; exists {
+		tokens = q.Refill(b.tokens, b.last, now)
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+
+	s.store[key] = bucket{
+		tokens: tokens,
+		last:   now,
+		full:   now.Add(time.Duration((float64(q.Limit) - tokens) / q.Rate() * float64(time.Second))),
+	}
+
+	return tokens, allowed, nil
+}
+
+// sweep removes the buckets that are full again since they hold no more
+// information than a missing bucket.
+func (s *Store) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.store {
+		if !now.Before(b.full) {
+			delete(s.store, key)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package session
+
+import (
+	"time"
+)
+
+// Session represents the server side state of a browser session.
+type Session struct {
+	ID          string
+	Subject     string
+	Roles       []string
+	CSRFToken   string
+	DateCreated time.Time
+	DateExpires time.Time
+}
+
+// NewSession contains information needed to create a new session.
+type NewSession struct {
+	Subject string
+	Roles   []string
+}
@@ -0,0 +1,312 @@
+// Package session provides support for server side browser sessions that are
+// tracked with cookies and protected against CSRF using a double-submit token.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/farmani/service/business/core/user"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Set of error variables for session operations.
+var (
+	ErrNotFound     = errors.New("session not found")
+	ErrExpired      = errors.New("session expired")
+	ErrInvalidCSRF  = errors.New("csrf token is missing or invalid")
+	ErrNoSessionKey = errors.New("session cookie not provided")
+	ErrUserDisabled = errors.New("user of the session is disabled")
+)
+
+// Names of the cookies and header used by the session support.
+const (
+	CookieName     = "sid"
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// =============================================================================
+
+// Storer interface declares the behavior this package needs to persists and
+// retrieve sessions.
+type Storer interface {
+	Create(ctx context.Context, sess Session) error
+	UpdateExpires(ctx context.Context, sess Session) error
+	Delete(ctx context.Context, sessionID string) error
+	QueryByID(ctx context.Context, sessionID string) (Session, error)
+}
+
+// UserCore interface declares the behavior this package needs from the user
+// core domain to keep the roles of a session current.
+type UserCore interface {
+	QueryByID(ctx context.Context, userID uuid.UUID) (user.User, error)
+}
+
+// Config represents information required to initialize session support.
+type Config struct {
+	Log      *zap.SugaredLogger
+	Storer   Storer
+	UserCore UserCore
+	TTL      time.Duration
+	Lifetime time.Duration
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// Manager manages the set of APIs for session access.
+type Manager struct {
+	log      *zap.SugaredLogger
+	storer   Storer
+	usrCore  UserCore
+	ttl      time.Duration
+	lifetime time.Duration
+	secure   bool
+	sameSite http.SameSite
+}
+
+// NewManager constructs a manager for session api access.
+func NewManager(cfg Config) *Manager {
+	sameSite := cfg.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+
+	return &Manager{
+		log:      cfg.Log,
+		storer:   cfg.Storer,
+		usrCore:  cfg.UserCore,
+		ttl:      cfg.TTL,
+		lifetime: cfg.Lifetime,
+		secure:   cfg.Secure,
+		sameSite: sameSite,
+	}
+}
+
+// Create starts a new session and returns it with the token that must be
+// handed to the client. Only a hash of the token is persisted.
+func (m *Manager) Create(ctx context.Context, ns NewSession, now time.Time) (Session, string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return Session{}, "", fmt.Errorf("generating session token: %w", err)
+	}
+
+	csrf, err := randomToken()
+	if err != nil {
+		return Session{}, "", fmt.Errorf("generating csrf token: %w", err)
+	}
+
+	sess := Session{
+		ID:          hashToken(token),
+		Subject:     ns.Subject,
+		Roles:       ns.Roles,
+		CSRFToken:   csrf,
+		DateCreated: now,
+		DateExpires: now.Add(m.ttl),
+	}
+
+	if err := m.storer.Create(ctx, sess); err != nil {
+		return Session{}, "", fmt.Errorf("create: %w", err)
+	}
+
+	return sess, token, nil
+}
+
+// Lookup finds the session for the specified token. The roles of the session
+// are reloaded from the user, so a role change applies right away and the
+// session ends when the user is disabled or removed. The expiry is slid
+// forward when more than half of the ttl has been used, bounded by the
+// configured absolute lifetime of a session.
+func (m *Manager) Lookup(ctx context.Context, token string, now time.Time) (Session, bool, error) {
+	sess, err := m.storer.QueryByID(ctx, hashToken(token))
+	if err != nil {
+		return Session{}, false, fmt.Errorf("query: %w", err)
+	}
+
+	if !now.Before(sess.DateExpires) {
+		if err := m.storer.Delete(ctx, sess.ID); err != nil {
+			m.log.Errorw("session", "status", "unable to delete expired session", "ERROR", err)
+		}
+		return Session{}, false, ErrExpired
+	}
+
+	roles, err := m.userRoles(ctx, sess.Subject)
+	if err != nil {
+		if errors.Is(err, ErrUserDisabled) {
+			if err := m.storer.Delete(ctx, sess.ID); err != nil {
+				m.log.Errorw("session", "status", "unable to delete session of disabled user", "ERROR", err)
+			}
+		}
+		return Session{}, false, err
+	}
+	sess.Roles = roles
+
+	if sess.DateExpires.Sub(now) > m.ttl/2 {
+		return sess, false, nil
+	}
+
+	expires := now.Add(m.ttl)
+	if m.lifetime > 0 {
+		if limit := sess.DateCreated.Add(m.lifetime); expires.After(limit) {
+			expires = limit
+		}
+	}
+
+	if !expires.After(sess.DateExpires) {
+		return sess, false, nil
+	}
+
+	sess.DateExpires = expires
+	if err := m.storer.UpdateExpires(ctx, sess); err != nil {
+		return Session{}, false, fmt.Errorf("update expires: %w", err)
+	}
+
+	return sess, true, nil
+}
+
+// Delete removes the session for the specified token.
+func (m *Manager) Delete(ctx context.Context, token string) error {
+	if err := m.storer.Delete(ctx, hashToken(token)); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// Token returns the session token provided by the client.
+func Token(r *http.Request) (string, error) {
+	c, err := r.Cookie(CookieName)
+	if err != nil || c.Value == "" {
+		return "", ErrNoSessionKey
+	}
+
+	return c.Value, nil
+}
+
+// CheckCSRF performs the double-submit check for methods that can change
+// state. The token in the header must match both the csrf cookie and the
+// token bound to the session.
+func CheckCSRF(r *http.Request, sess Session) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return nil
+	}
+
+	header := r.Header.Get(CSRFHeaderName)
+	if header == "" {
+		return ErrInvalidCSRF
+	}
+
+	c, err := r.Cookie(CSRFCookieName)
+	if err != nil {
+		return ErrInvalidCSRF
+	}
+
+	if subtle.ConstantTimeCompare([]byte(header), []byte(c.Value)) != 1 {
+		return ErrInvalidCSRF
+	}
+
+	if subtle.ConstantTimeCompare([]byte(header), []byte(sess.CSRFToken)) != 1 {
+		return ErrInvalidCSRF
+	}
+
+	return nil
+}
+
+// SetCookies writes the session and csrf cookies for the session. The csrf
+// cookie is readable by scripts so it can be echoed back in the header.
+func (m *Manager) SetCookies(w http.ResponseWriter, sess Session, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  sess.DateExpires,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: m.sameSite,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    sess.CSRFToken,
+		Path:     "/",
+		Expires:  sess.DateExpires,
+		HttpOnly: false,
+		Secure:   m.secure,
+		SameSite: m.sameSite,
+	})
+}
+
+// ClearCookies instructs the client to drop the session and csrf cookies.
+func (m *Manager) ClearCookies(w http.ResponseWriter) {
+	for _, name := range []string{CookieName, CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == CookieName,
+			Secure:   m.secure,
+			SameSite: m.sameSite,
+		})
+	}
+}
+
+// userRoles returns the current roles of the user a session belongs to. A
+// user that was disabled or removed since the session was created reports
+// ErrUserDisabled.
+func (m *Manager) userRoles(ctx context.Context, subject string) ([]string, error) {
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("parse subject[%s]: %w", subject, err)
+	}
+
+	usr, err := m.usrCore.QueryByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, ErrUserDisabled
+		}
+		return nil, fmt.Errorf("query user: userID[%s]: %w", userID, err)
+	}
+
+	if !usr.Enabled {
+		return nil, ErrUserDisabled
+	}
+
+	roles := make([]string, len(usr.Roles))
+	for i, role := range usr.Roles {
+		roles[i] = role.Name()
+	}
+
+	return roles, nil
+}
+
+// =============================================================================
+
+// randomToken generates a url safe token with 256 bits of entropy.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the key a session is stored under so a leaked store
+// can't be used to hijack sessions.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
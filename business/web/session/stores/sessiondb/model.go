@@ -0,0 +1,53 @@
+package sessiondb
+
+import (
+	"time"
+
+	"github.com/farmani/service/business/sys/database/pgx/dbarray"
+	"github.com/farmani/service/business/web/session"
+)
+
+// dbSession represent the structure we need for moving data
+// between the app and the database.
+type dbSession struct {
+	ID          string         `db:"session_id"`
+	Subject     string         `db:"subject"`
+	Roles       dbarray.String `db:"roles"`
+	CSRFToken   string         `db:"csrf_token"`
+	DateCreated time.Time      `db:"date_created"`
+	DateExpires time.Time      `db:"date_expires"`
+}
+
+// Redacted keeps the session id and csrf token out of the query logs.
+func (dbSession) Redacted() {}
+
+// dbSessionID represent the key of a session in queries. Like the rest of the
+// session, it is kept out of the query logs.
+type dbSessionID struct {
+	ID string `db:"session_id"`
+}
+
+// Redacted keeps the session id out of the query logs.
+func (dbSessionID) Redacted() {}
+
+func toDBSession(sess session.Session) dbSession {
+	return dbSession{
+		ID:          sess.ID,
+		Subject:     sess.Subject,
+		Roles:       sess.Roles,
+		CSRFToken:   sess.CSRFToken,
+		DateCreated: sess.DateCreated.UTC(),
+		DateExpires: sess.DateExpires.UTC(),
+	}
+}
+
+func toCoreSession(dbSess dbSession) session.Session {
+	return session.Session{
+		ID:          dbSess.ID,
+		Subject:     dbSess.Subject,
+		Roles:       dbSess.Roles,
+		CSRFToken:   dbSess.CSRFToken,
+		DateCreated: dbSess.DateCreated.In(time.Local),
+		DateExpires: dbSess.DateExpires.In(time.Local),
+	}
+}
@@ -0,0 +1,125 @@
+// Package sessiondb contains session related CRUD functionality.
+package sessiondb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	database "github.com/farmani/service/business/sys/database/pgx"
+	"github.com/farmani/service/business/web/session"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Store manages the set of APIs for session database access.
+type Store struct {
+	log *zap.SugaredLogger
+	db  sqlx.ExtContext
+}
+
+// NewStore constructs the api for data access.
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) *Store {
+	return &Store{
+		log: log,
+		db:  db,
+	}
+}
+
+// Create inserts a new session into the database.
+func (s *Store) Create(ctx context.Context, sess session.Session) error {
+	const q = `
+	INSERT INTO sessions
+		(session_id, subject, roles, csrf_token, date_created, date_expires)
+	VALUES
+		(:session_id, :subject, :roles, :csrf_token, :date_created, :date_expires)`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBSession(sess)); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateExpires replaces the expiration of a session in the database.
+func (s *Store) UpdateExpires(ctx context.Context, sess session.Session) error {
+	const q = `
+	UPDATE
+		sessions
+	SET
+		"date_expires" = :date_expires
+	WHERE
+		session_id = :session_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, toDBSession(sess)); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes a session from the database.
+func (s *Store) Delete(ctx context.Context, sessionID string) error {
+	data := dbSessionID{
+		ID: sessionID,
+	}
+
+	const q = `
+	DELETE FROM
+		sessions
+	WHERE
+		session_id = :session_id`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
+
+// QueryByID gets the specified session from the database.
+func (s *Store) QueryByID(ctx context.Context, sessionID string) (session.Session, error) {
+	data := dbSessionID{
+		ID: sessionID,
+	}
+
+	const q = `
+	SELECT
+		session_id, subject, roles, csrf_token, date_created, date_expires
+	FROM
+		sessions
+	WHERE
+		session_id = :session_id`
+
+	var dbSess dbSession
+	if err := database.NamedQueryStruct(ctx, s.log, s.db, q, data, &dbSess); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return session.Session{}, fmt.Errorf("namedquerystruct: %w", session.ErrNotFound)
+		}
+		return session.Session{}, fmt.Errorf("namedquerystruct: %w", err)
+	}
+
+	return toCoreSession(dbSess), nil
+}
+
+// Purge removes the sessions that expired before the specified time.
+func (s *Store) Purge(ctx context.Context, before time.Time) error {
+	data := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before.UTC(),
+	}
+
+	const q = `
+	DELETE FROM
+		sessions
+	WHERE
+		date_expires < :before`
+
+	if err := database.NamedExecContext(ctx, s.log, s.db, q, data); err != nil {
+		return fmt.Errorf("namedexeccontext: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package sessionmem contains session related CRUD functionality kept in
+// memory. It is used when a database isn't available for sessions.
+package sessionmem
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/farmani/service/business/web/session"
+)
+
+// Store manages the set of APIs for session access in memory.
+type Store struct {
+	mu    sync.RWMutex
+	store map[string]session.Session
+}
+
+// NewStore constructs the api for memory access.
+func NewStore() *Store {
+	return &Store{
+		store: make(map[string]session.Session),
+	}
+}
+
+// Create adds a new session to the store.
+func (s *Store) Create(ctx context.Context, sess session.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evict(time.Now())
+	s.store[sess.ID] = sess
+
+	return nil
+}
+
+// UpdateExpires replaces the expiration of a session in the store.
+func (s *Store) UpdateExpires(ctx context.Context, sess session.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, exists := s.store[sess.ID]
+	if !exists {
+		return session.ErrNotFound
+	}
+
+	cur.DateExpires = sess.DateExpires
+	s.store[sess.ID] = cur
+
+	return nil
+}
+
+// Delete removes a session from the store.
+func (s *Store) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.store, sessionID)
+
+	return nil
+}
+
+// QueryByID gets the specified session from the store.
+func (s *Store) QueryByID(ctx context.Context, sessionID string) (session.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sess, exists := s.store[sessionID]
+	if !exists {
+		return session.Session{}, session.ErrNotFound
+	}
+
+	return sess, nil
+}
+
+// evict removes the sessions that have expired. The caller must hold the
+// write lock.
+func (s *Store) evict(now time.Time) {
+	for id, sess := range s.store {
+		if !now.Before(sess.DateExpires) {
+			delete(s.store, id)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"net/http/pprof"
 
 	"github.com/farmani/service/app/services/sales-api/handlers/v1/checkgrp"
+	"github.com/farmani/service/business/web/lifecycle"
+	"github.com/farmani/service/business/web/metrics"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
 )
@@ -28,17 +30,20 @@ func StandardLibraryMux() *http.ServeMux {
 	return mux
 }
 
-func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+func Mux(build string, log *zap.SugaredLogger, db *sqlx.DB, lc *lifecycle.Manager) http.Handler {
 	mux := StandardLibraryMux()
 
 	chgrp := checkgrp.Handlers{
-		Build: build,
-		Log:   log,
-		DB:    db,
+		Build:     build,
+		Log:       log,
+		DB:        db,
+		Lifecycle: lc,
 	}
 
 	mux.HandleFunc("/v1/debug/readiness", chgrp.Readiness)
 	mux.HandleFunc("/v1/debug/liveness", chgrp.Liveness)
+	mux.HandleFunc("/v1/debug/shutdown", chgrp.Shutdown)
+	mux.Handle("/metrics", metrics.Handler())
 
 	return mux
 }
@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/farmani/service/business/sys/validate"
+	"github.com/farmani/service/foundation/web"
+)
+
+// Decode reads the JSON body of the request into the value using web.Decode.
+// Problems with the body or one of its fields are returned as FieldErrors,
+// like the errors of a failed validation. A value without a Validate method
+// is checked against its validate tags.
+func Decode(r *http.Request, val any) error {
+	if err := web.Decode(r, val); err != nil {
+		if de := web.GetDecodeError(err); de != nil && de.Field != "" {
+			return validate.NewFieldsError(de.Field, de.Err)
+		}
+		return err
+	}
+
+	if _, ok := val.(interface{ Validate() error }); ok {
+		return nil
+	}
+
+	return validate.Check(val)
+}
@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/idempotency"
+	"github.com/farmani/service/business/web/ratelimit"
+	"github.com/farmani/service/business/web/session"
+	"github.com/farmani/service/foundation/web"
+)
+
+// ErrorCode represents the stable code a client receives for an error, with
+// the status used when the error isn't wrapped in a RequestError. The title
+// defaults to the text of the status.
+type ErrorCode struct {
+	Code   string
+	Title  string
+	Status int
+}
+
+// registeredCode ties an error to its code.
+type registeredCode struct {
+	err  error
+	code ErrorCode
+}
+
+// errorCodes is the registry of error codes. The errors are matched in
+// order, so a more specific error must come before the errors it wraps.
+var errorCodes = struct {
+	mu   sync.RWMutex
+	list []registeredCode
+}{
+	list: []registeredCode{
+		{user.ErrNotFound, ErrorCode{Code: "user_not_found", Title: "User not found", Status: http.StatusNotFound}},
+		{user.ErrUniqueEmail, ErrorCode{Code: "email_not_unique", Title: "Email is not unique", Status: http.StatusConflict}},
+		{user.ErrConflict, ErrorCode{Code: "user_modified", Title: "User was modified", Status: http.StatusPreconditionFailed}},
+		{user.ErrAuthenticationFailure, ErrorCode{Code: "authentication_failed", Title: "Authentication failed", Status: http.StatusUnauthorized}},
+		{product.ErrNotFound, ErrorCode{Code: "product_not_found", Title: "Product not found", Status: http.StatusNotFound}},
+		{product.ErrInvalidUser, ErrorCode{Code: "product_user_invalid", Title: "Product user is not valid", Status: http.StatusBadRequest}},
+		{product.ErrInvalidCost, ErrorCode{Code: "product_cost_invalid", Title: "Product cost is not valid", Status: http.StatusBadRequest}},
+		{product.ErrConflict, ErrorCode{Code: "product_modified", Title: "Product was modified", Status: http.StatusPreconditionFailed}},
+		{auth.ErrForbidden, ErrorCode{Code: "forbidden", Title: "Action is not allowed", Status: http.StatusForbidden}},
+		{session.ErrNotFound, ErrorCode{Code: "session_not_found", Title: "Session not found", Status: http.StatusUnauthorized}},
+		{session.ErrExpired, ErrorCode{Code: "session_expired", Title: "Session expired", Status: http.StatusUnauthorized}},
+		{session.ErrInvalidCSRF, ErrorCode{Code: "csrf_invalid", Title: "CSRF token is invalid", Status: http.StatusForbidden}},
+		{session.ErrNoSessionKey, ErrorCode{Code: "session_missing", Title: "Session not provided", Status: http.StatusUnauthorized}},
+		{idempotency.ErrInvalidKey, ErrorCode{Code: "idempotency_key_invalid", Title: "Idempotency key is not valid", Status: http.StatusBadRequest}},
+		{idempotency.ErrMismatch, ErrorCode{Code: "idempotency_key_reused", Title: "Idempotency key was reused", Status: http.StatusUnprocessableEntity}},
+		{idempotency.ErrInProgress, ErrorCode{Code: "idempotency_key_in_progress", Title: "Request is in progress", Status: http.StatusConflict}},
+		{ratelimit.ErrRateLimited, ErrorCode{Code: "rate_limited", Title: "Rate limit exceeded", Status: http.StatusTooManyRequests}},
+		{web.ErrPreconditionFailed, ErrorCode{Code: "precondition_failed", Title: "Resource was modified", Status: http.StatusPreconditionFailed}},
+		{web.ErrNotAcceptable, ErrorCode{Code: "not_acceptable", Title: "Media type is not acceptable", Status: http.StatusNotAcceptable}},
+		{web.ErrTimeout, ErrorCode{Code: "timeout", Title: "Request timed out", Status: http.StatusGatewayTimeout}},
+		{web.ErrOverloaded, ErrorCode{Code: "overloaded", Title: "Server is overloaded", Status: http.StatusServiceUnavailable}},
+	},
+}
+
+// RegisterErrorCode adds the code for the error to the registry, replacing
+// the code already registered for the same error.
+func RegisterErrorCode(err error, code ErrorCode) {
+	errorCodes.mu.Lock()
+	defer errorCodes.mu.Unlock()
+
+	for i, rc := range errorCodes.list {
+		if rc.err == err {
+			errorCodes.list[i].code = code
+			return
+		}
+	}
+
+	errorCodes.list = append(errorCodes.list, registeredCode{err: err, code: code})
+}
+
+// LookupErrorCode returns the code registered for the first error of the
+// registry found in the chain of the error.
+func LookupErrorCode(err error) (ErrorCode, bool) {
+	errorCodes.mu.RLock()
+	defer errorCodes.mu.RUnlock()
+
+	for _, rc := range errorCodes.list {
+		if errors.Is(err, rc.err) {
+			return rc.code, true
+		}
+	}
+
+	return ErrorCode{}, false
+}
+
+// StatusErrorCode returns the code used for a status when no code is
+// registered for the error, like "not_found" for 404.
+func StatusErrorCode(status int) ErrorCode {
+	code := "unknown_error"
+	if text := http.StatusText(status); text != "" {
+		code = strings.ReplaceAll(strings.ToLower(text), " ", "_")
+	}
+
+	return ErrorCode{
+		Code:   code,
+		Status: status,
+	}
+}
@@ -2,9 +2,17 @@ package middlewares
 
 import (
 	"context"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
 	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/session"
+	v1 "github.com/farmani/service/business/web/v1"
 	"github.com/farmani/service/foundation/web"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"net/http"
 )
 
@@ -13,15 +21,78 @@ var (
 	ErrInvalidID = errors.New("ID is not in its proper form")
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
+// Authenticate validates a JWT from the `Authorization` header. Internal
+// services calling without the header are authenticated by the client
+// certificate they presented instead, when it was verified by the TLS
+// handshake.
 func Authenticate(a *auth.Auth) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if cert := clientCertificate(r); cert != nil && r.Header.Get("authorization") == "" {
+				claims, err := a.AuthenticateCertificate(cert)
+				if err != nil {
+					return auth.NewAuthError("authenticate: certificate: %s", err)
+				}
+
+				ctx = auth.SetClaims(ctx, claims)
+
+				return handler(ctx, w, r)
+			}
+
 			claims, err := a.Authenticate(ctx, r.Header.Get("authorization"))
+			if err != nil {
+				return auth.NewAuthErrorKind(auth.TokenErrorKind(err), "authenticate: failed: %s", err)
+			}
+
+			ctx = auth.SetClaims(ctx, claims)
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// AuthenticateSession validates the session cookie of a browser client and
+// produces the same claims as a JWT would. Requests that provide an
+// `Authorization` header or a client certificate are authenticated by
+// Authenticate instead. Unsafe methods using the session must pass the
+// double-submit CSRF check.
+func AuthenticateSession(a *auth.Auth, sm *session.Manager) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if r.Header.Get("authorization") != "" || clientCertificate(r) != nil {
+				return Authenticate(a)(handler)(ctx, w, r)
+			}
+
+			token, err := session.Token(r)
 			if err != nil {
 				return auth.NewAuthError("authenticate: failed: %s", err)
 			}
 
+			sess, refreshed, err := sm.Lookup(ctx, token, web.GetTime(ctx))
+			if err != nil {
+				if errors.Is(err, session.ErrExpired) {
+					return auth.NewAuthErrorKind(auth.KindExpired, "authenticate: session: %s", err)
+				}
+				return auth.NewAuthError("authenticate: session: %s", err)
+			}
+
+			if err := session.CheckCSRF(r, sess); err != nil {
+				return v1.NewRequestError(err, http.StatusForbidden)
+			}
+
+			if refreshed {
+				sm.SetCookies(w, sess, token)
+			}
+
+			claims, err := sessionClaims(sess)
+			if err != nil {
+				return auth.NewAuthError("authenticate: session claims: %s", err)
+			}
+
 			ctx = auth.SetClaims(ctx, claims)
 
 			return handler(ctx, w, r)
@@ -34,7 +105,8 @@ func Authenticate(a *auth.Auth) web.Middleware {
 }
 
 // Authorize validates that an authenticated user has at least one role from a
-// specified list. This method constructs the actual function that is used.
+// specified list. The rule is evaluated without the owner of a resource, so
+// routes with ownership rules use AuthorizeUser or AuthorizeProduct instead.
 func Authorize(a *auth.Auth, rule string) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -43,8 +115,102 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
 			}
 
-			if err := a.Authorize(ctx, claims, rule); err != nil {
-				return auth.NewAuthError("authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
+			if err := a.AuthorizeRoles(ctx, claims, rule); err != nil {
+				return auth.NewAuthErrorKind(auth.KindForbidden, "authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// AuthorizeUser executes the specified authorization rule against the user
+// identified by the `user_id` path parameter. The user is loaded first so its
+// ID is used as the owner of the resource, and it is stored in the context
+// for the handler to use.
+func AuthorizeUser(a *auth.Auth, usrCore *user.Core, rule string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			var userID uuid.UUID
+
+			if id := web.Param(r, "user_id"); id != "" {
+				var err error
+				userID, err = uuid.Parse(id)
+				if err != nil {
+					return v1.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+				}
+
+				usr, err := usrCore.QueryByID(ctx, userID)
+				if err != nil {
+					switch {
+					case errors.Is(err, user.ErrNotFound):
+						return v1.NewRequestError(err, http.StatusNotFound)
+					default:
+						return fmt.Errorf("querybyid: userID[%s]: %w", userID, err)
+					}
+				}
+
+				ctx = setUser(ctx, usr)
+			}
+
+			claims := auth.GetClaims(ctx)
+			if claims.Subject == "" {
+				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+			}
+
+			if err := a.Authorize(ctx, claims, userID, rule); err != nil {
+				return auth.NewAuthErrorKind(auth.KindForbidden, "authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// AuthorizeProduct executes the specified authorization rule against the
+// product identified by the `product_id` path parameter. The owner of the
+// product is used as the owner of the resource, and the product is stored in
+// the context for the handler to use.
+func AuthorizeProduct(a *auth.Auth, prdCore *product.Core, rule string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			var userID uuid.UUID
+
+			if id := web.Param(r, "product_id"); id != "" {
+				productID, err := uuid.Parse(id)
+				if err != nil {
+					return v1.NewRequestError(ErrInvalidID, http.StatusBadRequest)
+				}
+
+				prd, err := prdCore.QueryByID(ctx, productID)
+				if err != nil {
+					switch {
+					case errors.Is(err, product.ErrNotFound):
+						return v1.NewRequestError(err, http.StatusNotFound)
+					default:
+						return fmt.Errorf("querybyid: productID[%s]: %w", productID, err)
+					}
+				}
+
+				userID = prd.UserID
+				ctx = setProduct(ctx, prd)
+			}
+
+			claims := auth.GetClaims(ctx)
+			if claims.Subject == "" {
+				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+			}
+
+			if err := a.Authorize(ctx, claims, userID, rule); err != nil {
+				return auth.NewAuthErrorKind(auth.KindForbidden, "authorize: you are not authorized for that action, claims[%v] rule[%v]: %s", claims.Roles, rule, err)
 			}
 
 			return handler(ctx, w, r)
@@ -55,3 +221,65 @@ func Authorize(a *auth.Auth, rule string) web.Middleware {
 
 	return m
 }
+
+// AuthorizeQuery runs a partial evaluation of the specified list rule and
+// stores the resulting access filter in the context. Handlers apply the
+// filter to the query so only the rows the caller can see are returned.
+func AuthorizeQuery(a *auth.Auth, rule string) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			claims := auth.GetClaims(ctx)
+			if claims.Subject == "" {
+				return auth.NewAuthError("authorize: you are not authorized for that action, no claims")
+			}
+
+			filter, err := a.QueryFilter(ctx, claims, rule)
+			if err != nil {
+				return fmt.Errorf("queryfilter: rule[%v]: %w", rule, err)
+			}
+
+			ctx = setAccessFilter(ctx, filter)
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// =============================================================================
+
+// sessionClaims converts a session into the claims used for authorization.
+func sessionClaims(sess session.Session) (auth.Claims, error) {
+	roles := make([]user.Role, len(sess.Roles))
+	for i, value := range sess.Roles {
+		var err error
+		roles[i], err = user.ParseRole(value)
+		if err != nil {
+			return auth.Claims{}, err
+		}
+	}
+
+	claims := auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   sess.Subject,
+			IssuedAt:  jwt.NewNumericDate(sess.DateCreated),
+			ExpiresAt: jwt.NewNumericDate(sess.DateExpires),
+		},
+		Roles: roles,
+	}
+
+	return claims, nil
+}
+
+// clientCertificate returns the client certificate of the request when it
+// was verified against the trusted CAs.
+func clientCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return r.TLS.VerifiedChains[0][0]
+}
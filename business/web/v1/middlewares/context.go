@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
+	"github.com/farmani/service/business/data/access"
+)
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// Set of keys used to store/retrieve resources loaded by authorization.
+const (
+	userKey ctxKey = iota + 1
+	productKey
+	accessKey
+)
+
+// =============================================================================
+
+func setUser(ctx context.Context, usr user.User) context.Context {
+	return context.WithValue(ctx, userKey, usr)
+}
+
+// GetUser returns the user loaded by AuthorizeUser from the context.
+func GetUser(ctx context.Context) (user.User, error) {
+	v, ok := ctx.Value(userKey).(user.User)
+	if !ok {
+		return user.User{}, errors.New("user not found in context")
+	}
+
+	return v, nil
+}
+
+func setProduct(ctx context.Context, prd product.Product) context.Context {
+	return context.WithValue(ctx, productKey, prd)
+}
+
+// GetProduct returns the product loaded by AuthorizeProduct from the context.
+func GetProduct(ctx context.Context) (product.Product, error) {
+	v, ok := ctx.Value(productKey).(product.Product)
+	if !ok {
+		return product.Product{}, errors.New("product not found in context")
+	}
+
+	return v, nil
+}
+
+func setAccessFilter(ctx context.Context, filter access.Filter) context.Context {
+	return context.WithValue(ctx, accessKey, filter)
+}
+
+// GetAccessFilter returns the access filter produced by AuthorizeQuery from
+// the context. When no filter was produced, no rows are allowed.
+func GetAccessFilter(ctx context.Context) access.Filter {
+	v, ok := ctx.Value(accessKey).(access.Filter)
+	if !ok {
+		return access.None()
+	}
+
+	return v
+}
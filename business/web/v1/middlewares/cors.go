@@ -0,0 +1,108 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/farmani/service/foundation/web"
+)
+
+// ErrCORSAnyOriginCredentials is returned when credentials are allowed for
+// every origin, which would let any site make authenticated requests.
+var ErrCORSAnyOriginCredentials = errors.New("cors: credentials can't be allowed for the \"*\" origin")
+
+// CORSConfig represents the settings for cross origin requests. An origin of
+// "*" allows every origin, but can't be combined with credentials.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Validate checks the settings are safe to serve with.
+func (cfg CORSConfig) Validate() error {
+	if !cfg.AllowCredentials {
+		return nil
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			return ErrCORSAnyOriginCredentials
+		}
+	}
+
+	return nil
+}
+
+// CORS sets the response headers needed for cross origin requests and answers
+// the preflight requests. Requests from origins that aren't allowed are
+// handled without the CORS headers so the browser blocks the response. The
+// config is expected to pass Validate, credentials are never allowed for the
+// "*" origin regardless.
+func CORS(cfg CORSConfig) web.Middleware {
+	origins := make(map[string]bool, len(cfg.AllowedOrigins))
+	var anyOrigin bool
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge.Seconds()))
+
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			hdr := w.Header()
+			hdr.Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin == "" || (!anyOrigin && !origins[strings.ToLower(origin)]) {
+				return handler(ctx, w, r)
+			}
+
+			switch {
+			case anyOrigin:
+				hdr.Set("Access-Control-Allow-Origin", "*")
+			default:
+				hdr.Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if cfg.AllowCredentials && !anyOrigin {
+				hdr.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !preflight {
+				if exposed != "" {
+					hdr.Set("Access-Control-Expose-Headers", exposed)
+				}
+				return handler(ctx, w, r)
+			}
+
+			hdr.Add("Vary", "Access-Control-Request-Method")
+			hdr.Add("Vary", "Access-Control-Request-Headers")
+			hdr.Set("Access-Control-Allow-Methods", methods)
+			hdr.Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				hdr.Set("Access-Control-Max-Age", maxAge)
+			}
+
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		}
+
+		return h
+	}
+
+	return m
+}
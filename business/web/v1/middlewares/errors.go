@@ -2,8 +2,12 @@ package middlewares
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/farmani/service/business/core/product"
+	"github.com/farmani/service/business/core/user"
 	"github.com/farmani/service/business/web/auth"
 
 	"github.com/farmani/service/business/sys/validate"
@@ -12,52 +16,48 @@ import (
 	"go.uber.org/zap"
 )
 
+// Set of codes for the problems that aren't tied to a registered error.
+const (
+	codeValidation     = "validation_failed"
+	codeTokenExpired   = "token_expired"
+	codeTokenMalformed = "token_malformed"
+)
+
+// authRealm is the protection space reported in the challenges.
+const authRealm = "sales-api"
+
 // Errors handles errors coming out of the call chain. It detects normal
 // application errors which are used to respond to the client in a uniform way.
-// Unexpected errors (status >= 500) are logged.
-func Errors(log *zap.SugaredLogger) web.Middleware {
+// Unexpected errors (status >= 500) are logged. The errors are sent as
+// problem responses. Clients that still parse the legacy ErrorResponse can
+// be served it in the negotiated format by turning legacy on, then only the
+// clients naming application/problem+json in their Accept header get
+// problem responses.
+func Errors(log *zap.SugaredLogger, legacy bool) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			if err := handler(ctx, w, r); err != nil {
 				log.Errorw("error", "trace_id", web.GetTraceID(ctx), "message", err)
 
-				var er v1.ErrorResponse
-				var status int
-
-				switch {
-				case validate.IsFieldErrors(err):
-					fieldErrors := validate.GetFieldErrors(err)
-					er = v1.ErrorResponse{
-						Error:  "data validation error",
-						Fields: fieldErrors.Fields(),
-					}
-					status = http.StatusBadRequest
-
-				case v1.IsRequestError(err):
-					reqErr := v1.GetRequestError(err)
-					er = v1.ErrorResponse{
-						Error: reqErr.Error(),
-					}
-					status = reqErr.Status
-
-				case auth.IsAuthError(err):
-					er = v1.ErrorResponse{
-						Error: http.StatusText(http.StatusUnauthorized),
-					}
-					status = http.StatusUnauthorized
+				prob := ErrorProblem(err)
+				prob.Instance = web.GetTraceID(ctx)
 
-				default:
-					er = v1.ErrorResponse{
-						Error: http.StatusText(http.StatusInternalServerError),
-					}
-					status = http.StatusInternalServerError
+				if ae := auth.GetAuthError(err); ae != nil {
+					w.Header().Set("WWW-Authenticate", authChallenge(ae.Kind(), r))
 				}
 
 				// back propagate the error to the Handle method
 				// we have to validate error there to be sure its because of shutdown or some other error
 				// cause response could not be written to the client
-				if err := web.Respond(ctx, w, er, status); err != nil {
-					return err
+				var respErr error
+				switch {
+				case legacy && !web.AcceptsExplicitly(r, v1.ProblemContentType):
+					respErr = web.Respond(ctx, w, prob.ErrorResponse(), prob.Status)
+				default:
+					respErr = web.RespondJSON(ctx, w, prob, prob.Status, v1.ProblemContentType)
+				}
+				if respErr != nil {
+					return respErr
 				}
 
 				// If we receive the shutdown err we need to return it
@@ -75,3 +75,108 @@ func Errors(log *zap.SugaredLogger) web.Middleware {
 
 	return m
 }
+
+// ErrorProblem converts the error into the problem sent to the client. The
+// code of the problem comes from the registry of error codes, or from the
+// status when no code is registered for the error. It's also used by the
+// handlers that report errors in the body of a successful response.
+func ErrorProblem(err error) v1.Problem {
+	var status int
+	var detail string
+	var fields map[string]string
+	var code string
+
+	switch {
+	case validate.IsFieldErrors(err):
+		fieldErrors := validate.GetFieldErrors(err)
+		status, detail, fields, code = http.StatusBadRequest, "data validation error", fieldErrors.Fields(), codeValidation
+
+	case web.IsDecodeError(err):
+		decErr := web.GetDecodeError(err)
+		if decErr.Field == "" {
+			status, detail = decErr.Status, decErr.Error()
+			break
+		}
+
+		fieldErrors := validate.GetFieldErrors(validate.NewFieldsError(decErr.Field, decErr.Err))
+		status, detail, fields, code = http.StatusBadRequest, "data validation error", fieldErrors.Fields(), codeValidation
+
+	case errors.Is(err, web.ErrPreconditionFailed),
+		errors.Is(err, user.ErrConflict),
+		errors.Is(err, product.ErrConflict):
+		status, detail = http.StatusPreconditionFailed, web.ErrPreconditionFailed.Error()
+
+	case errors.Is(err, web.ErrNotAcceptable):
+		status, detail = http.StatusNotAcceptable, web.ErrNotAcceptable.Error()
+
+	case errors.Is(err, web.ErrTimeout):
+		status, detail = http.StatusGatewayTimeout, web.ErrTimeout.Error()
+
+	case errors.Is(err, web.ErrOverloaded):
+		status, detail = http.StatusServiceUnavailable, web.ErrOverloaded.Error()
+
+	case v1.IsRequestError(err):
+		reqErr := v1.GetRequestError(err)
+		status, detail = reqErr.Status, reqErr.Error()
+
+	case auth.IsAuthError(err):
+		status = http.StatusUnauthorized
+		switch auth.GetAuthError(err).Kind() {
+		case auth.KindForbidden:
+			status = http.StatusForbidden
+		case auth.KindExpired:
+			code = codeTokenExpired
+		case auth.KindMalformed:
+			code = codeTokenMalformed
+		}
+		detail = http.StatusText(status)
+
+	default:
+		// Errors with a registered code are expected errors, so their
+		// status and title are used. The message of the error itself may
+		// expose internal details and isn't sent.
+		if ec, exists := v1.LookupErrorCode(err); exists {
+			prob := v1.NewProblem(ec, "", "")
+			prob.Detail = prob.Title
+			return prob
+		}
+
+		status, detail = http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	}
+
+	ec := v1.StatusErrorCode(status)
+	if registered, exists := v1.LookupErrorCode(err); exists {
+		ec.Code, ec.Title = registered.Code, registered.Title
+	}
+	if code != "" {
+		ec.Code, ec.Title = code, ""
+	}
+
+	prob := v1.NewProblem(ec, detail, "")
+	prob.Fields = fields
+
+	return prob
+}
+
+// authChallenge constructs the bearer challenge for an auth failure. The
+// error attribute is left out when the request carried no token, and the
+// description is kept generic since the reason is only logged.
+// https://www.rfc-editor.org/rfc/rfc6750#section-3
+func authChallenge(kind string, r *http.Request) string {
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+
+	switch kind {
+	case auth.KindForbidden:
+		return challenge + `, error="insufficient_scope", error_description="the token does not grant access to this resource"`
+	case auth.KindExpired:
+		return challenge + `, error="invalid_token", error_description="the token has expired"`
+	case auth.KindMalformed:
+		return challenge + `, error="invalid_token", error_description="the token is malformed"`
+	}
+
+	if r.Header.Get("Authorization") == "" {
+		return challenge
+	}
+
+	return challenge + `, error="invalid_token"`
+}
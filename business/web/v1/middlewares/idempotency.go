@@ -0,0 +1,162 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+
+	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/idempotency"
+	v1 "github.com/farmani/service/business/web/v1"
+	"github.com/farmani/service/foundation/web"
+	"go.uber.org/zap"
+)
+
+// Idempotency makes POST and PATCH requests sent with an Idempotency-Key
+// header safe to retry. The response of the first request is stored and
+// replayed for the retries. Keys are scoped to the client, so the middleware
+// must come after the authentication middleware. Failed requests release the
+// key so they can be retried. Credentials set by the handler, like cookies,
+// are never stored or replayed, so the middleware doesn't belong on routes
+// that hand out credentials. A nil manager disables the support.
+func Idempotency(log *zap.SugaredLogger, im *idempotency.Manager) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		if im == nil {
+			return handler
+		}
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			key := r.Header.Get(idempotency.Header)
+			if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+				return handler(ctx, w, r)
+			}
+
+			if err := idempotency.ValidKey(key); err != nil {
+				return v1.NewRequestError(err, http.StatusBadRequest)
+			}
+
+			// The body is read for the fingerprint and put back for the
+			// handler. Anything over the limit is left for web.Decode to
+			// reject.
+			body, err := io.ReadAll(io.LimitReader(r.Body, web.MaxBodySize+1))
+			if err != nil {
+				return v1.NewRequestError(fmt.Errorf("reading body: %w", err), http.StatusBadRequest)
+			}
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+
+			key = idempotencyClient(ctx, r) + "|" + key
+			fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+
+			resp, replay, err := im.Begin(ctx, key, fingerprint, web.GetTime(ctx))
+			switch {
+			case errors.Is(err, idempotency.ErrMismatch):
+				return v1.NewRequestError(err, http.StatusUnprocessableEntity)
+			case errors.Is(err, idempotency.ErrInProgress):
+				return v1.NewRequestError(err, http.StatusConflict)
+			case err != nil:
+				return fmt.Errorf("idempotency: %w", err)
+			}
+
+			if replay {
+				hdr := w.Header()
+				for k, vs := range resp.Header {
+					if !credentialHeaders[k] {
+						hdr[k] = vs
+					}
+				}
+				hdr.Set(idempotency.ReplayedHeader, "true")
+
+				web.SetStatusCode(ctx, resp.StatusCode)
+				w.WriteHeader(resp.StatusCode)
+				_, err := w.Write(resp.Body)
+
+				return err
+			}
+
+			// The stored response is replayed to clients that may accept
+			// different encodings, so it is kept uncompressed.
+			web.DisableCompression(ctx)
+
+			before := w.Header().Clone()
+			cw := web.NewCaptureWriter(w, idempotency.MaxResponseSize)
+
+			// The key must be settled even when the client goes away.
+			sctx := context.WithoutCancel(ctx)
+
+			if err := handler(ctx, cw, r); err != nil {
+				if err := im.Release(sctx, key); err != nil {
+					log.Errorw("idempotency", "traceid", web.GetTraceID(ctx), "status", "unable to release key", "ERROR", err)
+				}
+				return err
+			}
+
+			data, complete := cw.Body()
+			if status := cw.StatusCode(); status == 0 || status >= http.StatusInternalServerError || !complete {
+				if err := im.Release(sctx, key); err != nil {
+					log.Errorw("idempotency", "traceid", web.GetTraceID(ctx), "status", "unable to release key", "ERROR", err)
+				}
+				return nil
+			}
+
+			resp = idempotency.Response{
+				StatusCode: cw.StatusCode(),
+				Header:     changedHeaders(before, cw.CapturedHeader()),
+				Body:       data,
+			}
+
+			if err := im.Complete(sctx, key, resp); err != nil {
+				log.Errorw("idempotency", "traceid", web.GetTraceID(ctx), "status", "unable to store response", "ERROR", err)
+			}
+
+			return nil
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// idempotencyClient identifies the client making the request so keys chosen
+// by different clients can't collide.
+func idempotencyClient(ctx context.Context, r *http.Request) string {
+	if claims := auth.GetClaims(ctx); claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// credentialHeaders are the response headers that can carry credentials,
+// which must not be kept in the store in plaintext.
+var credentialHeaders = map[string]bool{
+	"Set-Cookie":    true,
+	"Authorization": true,
+}
+
+// changedHeaders returns the headers set by the handler, leaving out the ones
+// set for every request by the middleware that runs before it and the ones
+// carrying credentials.
+func changedHeaders(before http.Header, after http.Header) http.Header {
+	changed := make(http.Header)
+	for k, vs := range after {
+		if credentialHeaders[k] {
+			continue
+		}
+		if !slices.Equal(before[k], vs) {
+			changed[k] = vs
+		}
+	}
+
+	return changed
+}
@@ -3,26 +3,41 @@ package middlewares
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/farmani/service/business/web/metrics"
 	"github.com/farmani/service/foundation/web"
 )
 
-// Metrics updates program counters.
+// Metrics updates program counters. The request metrics are labeled by the
+// route pattern so the cardinality doesn't grow with the parameters.
 func Metrics() web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			ctx = metrics.Set(ctx)
 
+			route := web.Route(r)
+			start := time.Now()
+
+			metrics.AddInFlight(ctx, route, r.Method, 1)
+			defer metrics.AddInFlight(ctx, route, r.Method, -1)
+
 			err := handler(ctx, w, r)
 
 			metrics.AddRequests(ctx)
 			metrics.AddGoroutines(ctx)
 
+			// The response for an error is written further up the chain so
+			// the status code is worked out the same way the Errors
+			// middleware does.
+			status := web.GetValues(ctx).StatusCode
 			if err != nil {
 				metrics.AddErrors(ctx)
+				status = ErrorProblem(err).Status
 			}
 
+			metrics.AddRequestDuration(ctx, route, r.Method, status, time.Since(start))
+
 			return err
 		}
 
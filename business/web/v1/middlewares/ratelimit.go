@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/farmani/service/business/web/auth"
+	"github.com/farmani/service/business/web/ratelimit"
+	v1 "github.com/farmani/service/business/web/v1"
+	"github.com/farmani/service/foundation/web"
+)
+
+// RateLimit limits the rate of requests a client can make to the route using
+// the quota configured for it. On authenticated routes the middleware goes
+// after the authentication middleware, so clients are identified by the
+// verified subject and callers sharing an ip address get their own bucket.
+// Anonymous routes carry no claims and are limited by the ip address. A nil
+// limiter disables rate limiting.
+func RateLimit(rl *ratelimit.Limiter) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		if rl == nil {
+			return handler
+		}
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			route := web.Route(r)
+
+			q := rl.Quota(r.Method, route)
+			if q.Unlimited() {
+				return handler(ctx, w, r)
+			}
+
+			key := r.Method + " " + route + "|" + rateLimitClient(ctx, rl, r)
+			res := rl.Allow(ctx, key, q, web.GetTime(ctx))
+
+			hdr := w.Header()
+			hdr.Set("RateLimit-Policy", q.Policy())
+			hdr.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			hdr.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			hdr.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+			if !res.Allowed {
+				hdr.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				return v1.NewRequestError(ratelimit.ErrRateLimited, http.StatusTooManyRequests)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// rateLimitClient identifies the client making the request. Only the subject
+// of the claims set by the authentication middleware is trusted, since
+// anything else the client sends can be changed to get a fresh bucket.
+func rateLimitClient(ctx context.Context, rl *ratelimit.Limiter, r *http.Request) string {
+	if claims := auth.GetClaims(ctx); claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+
+	return "ip:" + rl.ClientIP(r)
+}
+
+// ceilSeconds formats the duration as a whole number of seconds rounded up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,51 @@
+package v1
+
+import "net/http"
+
+// ProblemContentType is the media type of the problem responses.
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix is the prefix of the URI identifying the type of a
+// problem, which is followed by its code.
+const problemTypePrefix = "urn:problem-type:sales:"
+
+// Problem is the form used for API responses from failures in the API,
+// following the problem details format. Code is a stable machine readable
+// value for the problem, so clients don't need to parse the detail.
+// https://www.rfc-editor.org/rfc/rfc7807
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// NewProblem constructs the problem for the error code. The instance
+// identifies the occurrence of the problem, like the trace ID of the request.
+func NewProblem(ec ErrorCode, detail string, instance string) Problem {
+	title := ec.Title
+	if title == "" {
+		title = http.StatusText(ec.Status)
+	}
+
+	return Problem{
+		Type:     problemTypePrefix + ec.Code,
+		Title:    title,
+		Status:   ec.Status,
+		Detail:   detail,
+		Instance: instance,
+		Code:     ec.Code,
+	}
+}
+
+// ErrorResponse converts the problem into the legacy form of the responses
+// for failures.
+func (p Problem) ErrorResponse() ErrorResponse {
+	return ErrorResponse{
+		Error:  p.Detail,
+		Fields: p.Fields,
+	}
+}
@@ -5,7 +5,8 @@ import (
 	"errors"
 )
 
-// ErrorResponse is the form used for API responses from failures in the API.
+// ErrorResponse is the legacy form used for API responses from failures in
+// the API. It's only sent when the legacy errors are turned on.
 type ErrorResponse struct {
 	Error  string            `json:"error"`
 	Fields map[string]string `json:"fields,omitempty"`
@@ -30,6 +31,12 @@ func (re *RequestError) Error() string {
 	return re.Err.Error()
 }
 
+// Unwrap returns the wrapped error, so the error codes registered for it are
+// found.
+func (re *RequestError) Unwrap() error {
+	return re.Err
+}
+
 // IsRequestError checks if an error of type RequestError exists.
 func IsRequestError(err error) bool {
 	var re *RequestError
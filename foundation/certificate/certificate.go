@@ -0,0 +1,211 @@
+// Package certificate provides support for serving TLS with a certificate
+// that is reloaded when its files change, so it can be rotated without a
+// restart.
+package certificate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store holds the certificate loaded from a pair of PEM files. The files are
+// read again by Reload once either of them changes.
+type Store struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modCert  time.Time
+	modKey   time.Time
+	loadedAt time.Time
+}
+
+// NewStore constructs a store with the certificate and key in the specified
+// files.
+func NewStore(certFile string, keyFile string) (*Store, error) {
+	s := Store{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetCertificate returns the current certificate. It's meant to be used as
+// the GetCertificate function of a tls.Config.
+func (s *Store) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cert, nil
+}
+
+// Reload loads the certificate again when its files were modified since it
+// was last loaded and reports whether it did. The current certificate is kept
+// when the new files can't be loaded, like while they are only partially
+// written.
+func (s *Store) Reload() (bool, error) {
+	modCert, err := modTime(s.certFile)
+	if err != nil {
+		return false, err
+	}
+
+	modKey, err := modTime(s.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.RLock()
+	unchanged := s.cert != nil && modCert.Equal(s.modCert) && modKey.Equal(s.modKey)
+	s.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("loading key pair: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cert = &cert
+	s.modCert = modCert
+	s.modKey = modKey
+	s.loadedAt = time.Now()
+
+	return true, nil
+}
+
+// LoadedAt returns when the current certificate was loaded.
+func (s *Store) LoadedAt() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.loadedAt
+}
+
+// modTime returns the time the file was last modified.
+func modTime(file string) (time.Time, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("stat: %w", err)
+	}
+
+	return fi.ModTime(), nil
+}
+
+// =============================================================================
+
+// Config represents the settings of the TLS connections. ClientCAFile is a
+// bundle of PEM encoded CAs used to verify the certificates clients present.
+type Config struct {
+	MinVersion   string
+	CipherSuites []string
+	ClientCAFile string
+}
+
+// TLSConfig constructs the configuration for a server presenting the
+// certificate of the store. An empty list of cipher suites uses the suites
+// Go selects, the suites only apply to TLS 1.2 and older. When a client CA
+// bundle is configured, the clients that present a certificate must present
+// one issued by those CAs, while clients without one are still accepted so
+// they can authenticate another way.
+func (s *Store) TLSConfig(cfg Config) (*tls.Config, error) {
+	minVersion, err := ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg := tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: s.GetCertificate,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pool, err := LoadCertPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return &tlsCfg, nil
+}
+
+// LoadCertPool reads a bundle of PEM encoded certificates into a pool.
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading ca bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in ca bundle %q", file)
+	}
+
+	return pool, nil
+}
+
+// ParseVersion parses a TLS version like "1.2". An empty version defaults
+// to TLS 1.2.
+func ParseVersion(version string) (uint16, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "tls") {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	}
+
+	return 0, fmt.Errorf("unknown tls version %q", version)
+}
+
+// ParseCipherSuites parses the names of cipher suites, like
+// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256". Only the suites Go considers
+// secure are accepted.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, cs := range tls.CipherSuites() {
+		known[cs.Name] = cs.ID
+	}
+
+	suites := make([]uint16, len(names))
+	for i, name := range names {
+		id, exists := known[strings.TrimSpace(name)]
+		if !exists {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites[i] = id
+	}
+
+	return suites, nil
+}
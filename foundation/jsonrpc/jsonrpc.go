@@ -0,0 +1,267 @@
+// Package jsonrpc provides support for serving JSON-RPC 2.0 calls, including
+// batches and notifications, independent of the transport they arrive on.
+// https://www.jsonrpc.org/specification
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Version is the version of the protocol sent and required in messages.
+const Version = "2.0"
+
+// Set of codes defined by the specification. The codes from CodeServerError
+// down to -32099 are reserved for errors defined by the server.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+	CodeServerError    = -32000
+)
+
+// DefaultMaxBatch is the number of calls a batch can hold when no limit is
+// configured.
+const DefaultMaxBatch = 100
+
+// =============================================================================
+
+// Request represents a call of a method. A request without an ID is a
+// notification, which gets no response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// IsNotification reports whether the request is a notification. A request
+// with a null ID isn't one.
+func (r Request) IsNotification() bool {
+	return r.ID == nil
+}
+
+// Response represents the outcome of a call. Exactly one of Result and Error
+// is set, and the ID is null when the ID of the request couldn't be read.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// Error represents the error object of a failed call. It's also returned by
+// methods to control the error sent to the client.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// NewError constructs an error object for the code and message.
+func NewError(code int, format string, args ...any) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// =============================================================================
+
+// Handler is the signature of the functions serving a method. The params are
+// nil when the request has none. Errors that aren't an *Error are sent as an
+// internal error without their message, so methods convert the errors the
+// client should see.
+type Handler func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Config represents the settings of a server.
+type Config struct {
+	MaxBatch int
+}
+
+// Server dispatches the calls to the methods registered with it.
+type Server struct {
+	maxBatch int
+
+	mu      sync.RWMutex
+	methods map[string]Handler
+}
+
+// NewServer constructs a server without any method.
+func NewServer(cfg Config) *Server {
+	maxBatch := cfg.MaxBatch
+	if maxBatch <= 0 {
+		maxBatch = DefaultMaxBatch
+	}
+
+	return &Server{
+		maxBatch: maxBatch,
+		methods:  make(map[string]Handler),
+	}
+}
+
+// Register sets the handler for the method, replacing the handler already
+// registered for it.
+func (s *Server) Register(method string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.methods[method] = handler
+}
+
+// Serve executes the call or the batch of calls in the message and returns
+// the response to send, a Response or a slice of them for a batch. The calls
+// of a batch are executed in order. False is returned when there is nothing
+// to send because the message only held notifications.
+func (s *Server) Serve(ctx context.Context, msg []byte) (any, bool) {
+	msg = bytes.TrimSpace(msg)
+
+	if len(msg) == 0 || msg[0] != '[' {
+		return s.call(ctx, msg)
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(msg, &batch); err != nil {
+		return ErrorResponse(nil, NewError(CodeParseError, "parse error: %s", err)), true
+	}
+
+	switch {
+	case len(batch) == 0:
+		return ErrorResponse(nil, NewError(CodeInvalidRequest, "invalid request: empty batch")), true
+	case len(batch) > s.maxBatch:
+		return ErrorResponse(nil, NewError(CodeInvalidRequest, "invalid request: batch of %d calls exceeds the limit of %d", len(batch), s.maxBatch)), true
+	}
+
+	var resps []Response
+	for _, raw := range batch {
+		if resp, ok := s.call(ctx, raw); ok {
+			resps = append(resps, resp)
+		}
+	}
+
+	if len(resps) == 0 {
+		return nil, false
+	}
+
+	return resps, true
+}
+
+// call executes a single call and returns its response, if it gets one.
+func (s *Server) call(ctx context.Context, raw json.RawMessage) (Response, bool) {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return ErrorResponse(nil, NewError(CodeParseError, "parse error: %s", err)), true
+		}
+		return ErrorResponse(nil, NewError(CodeInvalidRequest, "invalid request: %s", err)), true
+	}
+
+	if err := validRequest(req); err != nil {
+		id := req.ID
+		if !validID(id) {
+			id = nil
+		}
+		return ErrorResponse(id, err), true
+	}
+
+	s.mu.RLock()
+	handler, exists := s.methods[req.Method]
+	s.mu.RUnlock()
+
+	if !exists {
+		if req.IsNotification() {
+			return Response{}, false
+		}
+		return ErrorResponse(req.ID, NewError(CodeMethodNotFound, "method %q not found", req.Method)), true
+	}
+
+	result, err := handler(ctx, req.Params)
+
+	if req.IsNotification() {
+		return Response{}, false
+	}
+
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = NewError(CodeInternalError, "internal error")
+		}
+		return ErrorResponse(req.ID, rpcErr), true
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return ErrorResponse(req.ID, NewError(CodeInternalError, "internal error")), true
+	}
+
+	resp := Response{
+		JSONRPC: Version,
+		Result:  data,
+		ID:      req.ID,
+	}
+
+	return resp, true
+}
+
+// ErrorResponse constructs the response for a failed call. A nil ID is sent
+// as null.
+func ErrorResponse(id json.RawMessage, rpcErr *Error) Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	return Response{
+		JSONRPC: Version,
+		Error:   rpcErr,
+		ID:      id,
+	}
+}
+
+// =============================================================================
+
+// validRequest checks the request is a valid call.
+func validRequest(req Request) *Error {
+	switch {
+	case req.JSONRPC != Version:
+		return NewError(CodeInvalidRequest, "invalid request: jsonrpc must be %q", Version)
+	case req.Method == "":
+		return NewError(CodeInvalidRequest, "invalid request: method must be provided")
+	case !validID(req.ID):
+		return NewError(CodeInvalidRequest, "invalid request: id must be a string, a number or null")
+	case req.Params != nil && !structured(req.Params):
+		return NewError(CodeInvalidRequest, "invalid request: params must be an object or an array")
+	}
+
+	return nil
+}
+
+// validID checks the ID is absent, a string, a number or null.
+func validID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+
+	switch c := id[0]; {
+	case c == '"', c == '-', c >= '0' && c <= '9':
+		return true
+	}
+
+	return string(id) == "null"
+}
+
+// structured checks the params are an object or an array.
+func structured(params json.RawMessage) bool {
+	return params[0] == '{' || params[0] == '['
+}
@@ -7,7 +7,6 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"github.com/golang-jwt/jwt/v5"
 	"io"
 	"io/fs"
 	"path"
@@ -23,7 +22,8 @@ type PrivateKey struct {
 // KeyStore represents an in memory store implementation of the
 // KeyLookup interface for use with the auth package.
 type KeyStore struct {
-	store map[string]PrivateKey
+	store    map[string]PrivateKey
+	loadErrs LoadErrors
 }
 
 // New constructs an empty KeyStore ready for use.
@@ -40,11 +40,54 @@ func NewMap(store map[string]PrivateKey) *KeyStore {
 	}
 }
 
+// Config represents the settings used when loading keys from PEM files.
+type Config struct {
+	Passphrase  []byte
+	SkipInvalid bool
+}
+
+// LoadError represents a PEM file that could not be loaded.
+type LoadError struct {
+	File string
+	Err  error
+}
+
+// Error implements the error interface.
+func (le *LoadError) Error() string {
+	return fmt.Sprintf("%s: %s", le.File, le.Err)
+}
+
+// Unwrap returns the reason the file could not be loaded.
+func (le *LoadError) Unwrap() error {
+	return le.Err
+}
+
+// LoadErrors represents the collection of PEM files that could not be loaded.
+type LoadErrors []*LoadError
+
+// Error implements the error interface.
+func (le LoadErrors) Error() string {
+	msgs := make([]string, len(le))
+	for i, err := range le {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
 // NewFS constructs a KeyStore based on a set of PEM files rooted inside
 // of a directory. The name of each PEM file will be used as the key id.
 // Example: keystore.NewFS(os.DirFS("/zarf/keys/"))
 // Example: /zarf/keys/54bb2165-71e1-41a6-af3e-7da4a0e1e2c1.pem
 func NewFS(fsys fs.FS) (*KeyStore, error) {
+	return NewFSConfig(fsys, Config{})
+}
+
+// NewFSConfig constructs a KeyStore like NewFS using the specified config.
+// PKCS#1 and PKCS#8 keys are supported and encrypted keys are decrypted with
+// the passphrase. Every file is attempted and the ones that fail are reported
+// together as LoadErrors. When SkipInvalid is set the failures are not
+// returned, but are available from the LoadErrors method.
+func NewFSConfig(fsys fs.FS, cfg Config) (*KeyStore, error) {
 	ks := New()
 
 	fn := func(fileName string, dirEntry fs.DirEntry, err error) error {
@@ -60,40 +103,117 @@ func NewFS(fsys fs.FS) (*KeyStore, error) {
 			return nil
 		}
 
-		file, err := fsys.Open(fileName)
+		key, err := loadKey(fsys, fileName, cfg.Passphrase)
 		if err != nil {
-			return fmt.Errorf("opening key file: %w", err)
+			ks.loadErrs = append(ks.loadErrs, &LoadError{File: fileName, Err: err})
+			return nil
 		}
-		defer file.Close()
 
-		// limit PEM file size to 1 megabyte. This should be reasonable for
-		// almost any PEM file and prevents shenanigans like linking the file
-		// to /dev/random or something like that.
-		pem, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-		if err != nil {
-			return fmt.Errorf("reading auth private key: %w", err)
+		ks.store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = key
+
+		return nil
+	}
+
+	if err := fs.WalkDir(fsys, ".", fn); err != nil {
+		return nil, fmt.Errorf("walking directory: %w", err)
+	}
+
+	if len(ks.loadErrs) > 0 && !cfg.SkipInvalid {
+		return nil, ks.loadErrs
+	}
+
+	return ks, nil
+}
+
+// LoadErrors returns the PEM files that were skipped while loading the store.
+func (ks *KeyStore) LoadErrors() LoadErrors {
+	return ks.loadErrs
+}
+
+// loadKey reads and parses the private key in the specified PEM file.
+func loadKey(fsys fs.FS, fileName string, passphrase []byte) (PrivateKey, error) {
+	file, err := fsys.Open(fileName)
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("opening key file: %w", err)
+	}
+	defer file.Close()
+
+	// limit PEM file size to 1 megabyte. This should be reasonable for
+	// almost any PEM file and prevents shenanigans like linking the file
+	// to /dev/random or something like that.
+	data, err := io.ReadAll(io.LimitReader(file, 1024*1024))
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("reading auth private key: %w", err)
+	}
+
+	pk, err := parsePrivateKey(data, passphrase)
+	if err != nil {
+		return PrivateKey{}, fmt.Errorf("parsing auth private key: %w", err)
+	}
+
+	// Keep the key in unencrypted form since that is what is handed out
+	// to sign tokens.
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(pk),
+	}
+
+	key := PrivateKey{
+		PK:  pk,
+		PEM: pem.EncodeToMemory(&block),
+	}
+
+	return key, nil
+}
+
+// parsePrivateKey decodes a PKCS#1 or PKCS#8 RSA private key that may be
+// encrypted with the passphrase.
+func parsePrivateKey(data []byte, passphrase []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	der := block.Bytes
+
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if len(passphrase) == 0 {
+			return nil, errors.New("key is encrypted and no passphrase was provided")
 		}
 
-		pk, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
-		if err != nil {
-			return fmt.Errorf("parsing auth private key: %w", err)
+		var err error
+		if der, err = decryptPKCS8(der, passphrase); err != nil {
+			return nil, fmt.Errorf("decrypting pkcs8: %w", err)
 		}
 
-		key := PrivateKey{
-			PK:  pk,
-			PEM: pem,
+	// Legacy encrypted PEM files are deprecated but still in use.
+	case x509.IsEncryptedPEMBlock(block):
+		if len(passphrase) == 0 {
+			return nil, errors.New("key is encrypted and no passphrase was provided")
 		}
 
-		ks.store[strings.TrimSuffix(dirEntry.Name(), ".pem")] = key
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, passphrase); err != nil {
+			return nil, fmt.Errorf("decrypting pem: %w", err)
+		}
+	}
 
-		return nil
+	if pk, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return pk, nil
 	}
 
-	if err := fs.WalkDir(fsys, ".", fn); err != nil {
-		return nil, fmt.Errorf("walking directory: %w", err)
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("key must be PKCS#1 or PKCS#8: %w", err)
 	}
 
-	return ks, nil
+	pk, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T, expected RSA", key)
+	}
+
+	return pk, nil
 }
 
 // PrivateKey searches the key store for a given kid and returns the private key.
@@ -0,0 +1,85 @@
+package keystore
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewFSConfigLoadErrors(t *testing.T) {
+	good, err := os.ReadFile("testdata/key.pem")
+	if err != nil {
+		t.Fatalf("reading key: %s", err)
+	}
+
+	encrypted, err := os.ReadFile("testdata/key-aes256-sha256.pem")
+	if err != nil {
+		t.Fatalf("reading encrypted key: %s", err)
+	}
+
+	fsys := fstest.MapFS{
+		"good.pem":           {Data: good},
+		"keys/nested.pem":    {Data: encrypted},
+		"garbage.pem":        {Data: []byte("not a key")},
+		"README.md":          {Data: []byte("ignored")},
+		"keys/unrelated.txt": {Data: []byte("ignored")},
+	}
+
+	t.Run("skip invalid", func(t *testing.T) {
+		ks, err := NewFSConfig(fsys, Config{SkipInvalid: true})
+		if err != nil {
+			t.Fatalf("loading: %s", err)
+		}
+
+		if _, err := ks.PrivateKey("good"); err != nil {
+			t.Fatalf("expected the good key to be loaded: %s", err)
+		}
+
+		if _, err := ks.PublicKey("good"); err != nil {
+			t.Fatalf("expected the public key of the good key: %s", err)
+		}
+
+		loadErrs := ks.LoadErrors()
+		if len(loadErrs) != 2 {
+			t.Fatalf("got %d load errors, exp 2: %v", len(loadErrs), loadErrs)
+		}
+
+		files := map[string]bool{}
+		for _, le := range loadErrs {
+			files[le.File] = true
+		}
+		if !files["garbage.pem"] || !files["keys/nested.pem"] {
+			t.Fatalf("got load errors for %v, exp garbage.pem and keys/nested.pem", files)
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		_, err := NewFSConfig(fsys, Config{})
+
+		var loadErrs LoadErrors
+		if !errors.As(err, &loadErrs) {
+			t.Fatalf("got error %v, exp LoadErrors", err)
+		}
+
+		if len(loadErrs) != 2 {
+			t.Fatalf("got %d load errors, exp 2: %v", len(loadErrs), loadErrs)
+		}
+	})
+
+	t.Run("passphrase", func(t *testing.T) {
+		ks, err := NewFSConfig(fsys, Config{Passphrase: []byte(passphrase), SkipInvalid: true})
+		if err != nil {
+			t.Fatalf("loading: %s", err)
+		}
+
+		if _, err := ks.PrivateKey("nested"); err != nil {
+			t.Fatalf("expected the encrypted key to be loaded: %s", err)
+		}
+
+		loadErrs := ks.LoadErrors()
+		if len(loadErrs) != 1 || loadErrs[0].File != "garbage.pem" {
+			t.Fatalf("got load errors %v, exp only garbage.pem", loadErrs)
+		}
+	})
+}
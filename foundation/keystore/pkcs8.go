@@ -0,0 +1,136 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Object identifiers for the PBES2 schemes supported for encrypted PKCS#8.
+var (
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                 `asn1:"optional"`
+	PRF            algorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts the DER of an encrypted PKCS#8 key using the
+// passphrase. Only PBES2 with PBKDF2 and AES-CBC is supported, which is what
+// `openssl genpkey -aes256` and `openssl pkcs8 -topk8 -v2 aes256` produce.
+func decryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("parsing encrypted key info: %w", err)
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption algorithm %s", info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("parsing pbes2 parameters: %w", err)
+	}
+
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("parsing pbkdf2 parameters: %w", err)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case kdf.PRF.Algorithm == nil, kdf.PRF.Algorithm.Equal(oidHMACSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACSHA256):
+		prf = sha256.New
+	case kdf.PRF.Algorithm.Equal(oidHMACSHA384):
+		prf = sha512.New384
+	case kdf.PRF.Algorithm.Equal(oidHMACSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported pseudorandom function %s", kdf.PRF.Algorithm)
+	}
+
+	var keyLen int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLen = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported cipher %s", params.EncryptionScheme.Algorithm)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("parsing cipher iv: %w", err)
+	}
+
+	key := pbkdf2.Key(passphrase, kdf.Salt, kdf.IterationCount, keyLen, prf)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iv) != block.BlockSize() || len(info.EncryptedData) == 0 || len(info.EncryptedData)%block.BlockSize() != 0 {
+		return nil, errors.New("invalid encrypted data size")
+	}
+
+	data := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, info.EncryptedData)
+
+	// Strip the PKCS#7 padding. A bad passphrase almost always shows up here.
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, errors.New("incorrect passphrase")
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("incorrect passphrase")
+		}
+	}
+
+	return data[:len(data)-pad], nil
+}
@@ -0,0 +1,191 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// The fixtures in testdata were produced by OpenSSL from key.pem with the
+// passphrase "gopher", like:
+//
+//	openssl pkcs8 -topk8 -in key.pem -v2 aes128 -v2prf hmacWithSHA256
+//	openssl rsa -in key.pem -traditional -aes256
+const passphrase = "gopher"
+
+func TestDecryptPKCS8(t *testing.T) {
+	exp := readDER(t, "testdata/key.pem", "PRIVATE KEY")
+
+	tests := []string{
+		"testdata/key-aes128-sha256.pem",
+		"testdata/key-aes192-sha256.pem",
+		"testdata/key-aes256-sha1.pem",
+		"testdata/key-aes256-sha256.pem",
+		"testdata/key-aes256-sha384.pem",
+		"testdata/key-aes256-sha512.pem",
+	}
+
+	for _, file := range tests {
+		t.Run(file, func(t *testing.T) {
+			der := readDER(t, file, "ENCRYPTED PRIVATE KEY")
+
+			got, err := decryptPKCS8(der, []byte(passphrase))
+			if err != nil {
+				t.Fatalf("decrypting: %s", err)
+			}
+
+			if !bytes.Equal(got, exp) {
+				t.Fatal("decrypted key doesn't match testdata/key.pem")
+			}
+
+			if _, err := decryptPKCS8(der, []byte("wrong")); err == nil {
+				t.Fatal("expected an error for the wrong passphrase")
+			}
+		})
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	tests := []struct {
+		file       string
+		passphrase string
+		err        string
+	}{
+		{file: "testdata/key.pem"},
+		{file: "testdata/key-aes256-sha256.pem", passphrase: passphrase},
+		{file: "testdata/key-legacy.pem", passphrase: passphrase},
+		{file: "testdata/key-aes256-sha256.pem", err: "no passphrase was provided"},
+		{file: "testdata/key-legacy.pem", err: "no passphrase was provided"},
+		{file: "testdata/key-aes256-sha256.pem", passphrase: "wrong", err: "incorrect passphrase"},
+	}
+
+	for _, tt := range tests {
+		data, err := os.ReadFile(tt.file)
+		if err != nil {
+			t.Fatalf("reading %s: %s", tt.file, err)
+		}
+
+		pk, err := parsePrivateKey(data, []byte(tt.passphrase))
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: parsing: %s", tt.file, err)
+		case tt.err == "" && pk.N.BitLen() != 2048:
+			t.Errorf("%s: got a %d bit key, exp 2048", tt.file, pk.N.BitLen())
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: got error %v, exp %q", tt.file, err, tt.err)
+		}
+	}
+}
+
+func TestDecryptPKCS8BadPadding(t *testing.T) {
+	tests := []struct {
+		name string
+		pad  []byte
+	}{
+		{name: "zero", pad: bytes.Repeat([]byte{0}, 16)},
+		{name: "larger than a block", pad: bytes.Repeat([]byte{17}, 16)},
+		{name: "inconsistent", pad: append(bytes.Repeat([]byte{3}, 14), 2, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := append(bytes.Repeat([]byte{'k'}, 32), tt.pad...)
+
+			_, err := decryptPKCS8(encryptPKCS8(t, plain), []byte(passphrase))
+			if err == nil || err.Error() != "incorrect passphrase" {
+				t.Fatalf("got error %v, exp %q", err, "incorrect passphrase")
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		plain := append(bytes.Repeat([]byte{'k'}, 32), bytes.Repeat([]byte{16}, 16)...)
+
+		got, err := decryptPKCS8(encryptPKCS8(t, plain), []byte(passphrase))
+		if err != nil {
+			t.Fatalf("decrypting: %s", err)
+		}
+
+		if !bytes.Equal(got, plain[:32]) {
+			t.Fatalf("got %q, exp %q", got, plain[:32])
+		}
+	})
+}
+
+// =============================================================================
+
+// readDER returns the bytes of the PEM block of the specified type.
+func readDER(t *testing.T, file string, blockType string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("reading %s: %s", file, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		t.Fatalf("%s: expected a %q PEM block", file, blockType)
+	}
+
+	return block.Bytes
+}
+
+// encryptPKCS8 encrypts the already padded data with PBES2 using PBKDF2 with
+// HMAC-SHA256 and AES-128-CBC, so the padding seen by decryptPKCS8 can be
+// controlled.
+func encryptPKCS8(t *testing.T, padded []byte) []byte {
+	t.Helper()
+
+	salt := []byte("saltsalt")
+	iv := bytes.Repeat([]byte{1}, aes.BlockSize)
+
+	key := pbkdf2.Key([]byte(passphrase), salt, 1000, 16, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("constructing cipher: %s", err)
+	}
+
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+
+	kdf, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: 1000,
+		PRF:            algorithmIdentifier{Algorithm: oidHMACSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		t.Fatalf("marshaling pbkdf2 parameters: %s", err)
+	}
+
+	ivDER, err := asn1.Marshal(iv)
+	if err != nil {
+		t.Fatalf("marshaling iv: %s", err)
+	}
+
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: algorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdf}},
+		EncryptionScheme:  algorithmIdentifier{Algorithm: oidAES128CBC, Parameters: asn1.RawValue{FullBytes: ivDER}},
+	})
+	if err != nil {
+		t.Fatalf("marshaling pbes2 parameters: %s", err)
+	}
+
+	der, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     algorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		t.Fatalf("marshaling encrypted key info: %s", err)
+	}
+
+	return der
+}
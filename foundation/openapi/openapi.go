@@ -0,0 +1,334 @@
+// Package openapi provides support for generating an OpenAPI 3 document from
+// the endpoints registered on a web app.
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/farmani/service/foundation/web"
+)
+
+// Version is the version of the OpenAPI specification the documents follow.
+const Version = "3.0.3"
+
+// problemMediaType is the media type of the problem responses.
+// https://www.rfc-editor.org/rfc/rfc7807
+const problemMediaType = "application/problem+json"
+
+// BearerAuth is the name of the security scheme used by endpoints that
+// require an authorization rule.
+const BearerAuth = "bearerAuth"
+
+// Info represents the metadata of the api described by a document. Error is
+// a value of the model sent back for failed requests. Problem is a value of
+// the model sent instead as application/problem+json, when the api supports
+// it.
+type Info struct {
+	Title       string
+	Description string
+	Version     string
+	Error       any
+	Problem     any
+}
+
+// Generate constructs the document describing the endpoints. Endpoints
+// without a summary are left out of the document.
+func Generate(info Info, endpoints []web.Endpoint) *Document {
+	g := generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+
+	doc := Document{
+		OpenAPI: Version,
+		Info: DocumentInfo{
+			Title:       info.Title,
+			Description: info.Description,
+			Version:     info.Version,
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				BearerAuth: {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+				},
+			},
+		},
+	}
+
+	var errorContent map[string]MediaType
+	if info.Error != nil {
+		errorContent = jsonContent(g.schema(reflect.TypeOf(info.Error)))
+	}
+	if info.Problem != nil {
+		if errorContent == nil {
+			errorContent = make(map[string]MediaType)
+		}
+		errorContent[problemMediaType] = MediaType{Schema: g.schema(reflect.TypeOf(info.Problem))}
+	}
+
+	for _, e := range endpoints {
+		if e.Doc.Summary == "" {
+			continue
+		}
+
+		route, params := pathParams(e.Path)
+
+		item, exists := doc.Paths[route]
+		if !exists {
+			item = make(PathItem)
+			doc.Paths[route] = item
+		}
+
+		item[strings.ToLower(e.Method)] = g.operation(e, params, errorContent)
+	}
+
+	return &doc
+}
+
+// =============================================================================
+
+// generator keeps track of the schemas of the named types referenced by the
+// endpoints so each is described once.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// operation constructs the description of a single endpoint.
+func (g *generator) operation(e web.Endpoint, params []Parameter, errorContent map[string]MediaType) Operation {
+	op := Operation{
+		Summary:     e.Doc.Summary,
+		Description: e.Doc.Description,
+		Tags:        e.Doc.Tags,
+		Parameters:  params,
+		Deprecated:  e.Deprecated,
+		Responses:   make(map[string]Response),
+	}
+
+	for _, qp := range e.Doc.Query {
+		typ := qp.Type
+		if typ == "" {
+			typ = "string"
+		}
+
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        qp.Name,
+			In:          "query",
+			Description: qp.Description,
+			Required:    qp.Required,
+			Schema:      &Schema{Type: typ},
+		})
+	}
+
+	if e.Doc.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  jsonContent(g.schema(reflect.TypeOf(e.Doc.Request))),
+		}
+	}
+
+	status := e.Doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	resp := Response{Description: http.StatusText(status)}
+	if e.Doc.Response != nil && status != http.StatusNoContent {
+		resp.Content = jsonContent(g.schema(reflect.TypeOf(e.Doc.Response)))
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+
+	if e.Doc.Auth != "" {
+		op.Security = []map[string][]string{{BearerAuth: {}}}
+		op.AuthRule = e.Doc.Auth
+	}
+
+	if errorContent != nil {
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     errorContent,
+		}
+	}
+
+	return op
+}
+
+// Set of types with a fixed description.
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schema describes the type. Named struct types are described once in the
+// components of the document and referenced from everywhere else.
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		s := Schema{Type: "string"}
+		if t.Name() == "UUID" {
+			s.Format = "uuid"
+		}
+		return &s
+
+	case t.Implements(jsonMarshalerType):
+		// The value can be any JSON value, like a json.RawMessage.
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := Schema{Type: "integer"}
+		if t.Bits() == 64 {
+			s.Format = "int64"
+		} else {
+			s.Format = "int32"
+		}
+		return &s
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+
+	case reflect.String:
+		return &Schema{Type: "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+
+	return &Schema{}
+}
+
+// component registers the description of the named struct type and returns
+// its name in the components of the document.
+func (g *generator) component(t reflect.Type) string {
+	if name, exists := g.names[t]; exists {
+		return name
+	}
+
+	name := path.Base(t.PkgPath()) + "." + t.Name()
+	g.names[t] = name
+
+	// The name is registered before the fields are described so recursive
+	// types end up referencing themselves.
+	g.schemas[name] = g.object(t)
+
+	return name
+}
+
+// object describes the fields of a struct the way encoding/json encodes them.
+// Fields with a required validate tag are marked as required.
+func (g *generator) object(t reflect.Type) *Schema {
+	s := Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		tag, hasTag := f.Tag.Lookup("json")
+		if n, _, _ := strings.Cut(tag, ","); n != "" {
+			name = n
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		switch {
+		case name == "-" && tag == "-":
+			continue
+		case f.Anonymous && !hasTag && ft.Kind() == reflect.Struct:
+			continue
+		}
+
+		s.Properties[name] = g.schema(f.Type)
+
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				s.Required = append(s.Required, name)
+				break
+			}
+		}
+	}
+
+	return &s
+}
+
+// pathParams converts the route from the syntax of the router to the syntax
+// of the document and returns its parameters.
+func pathParams(route string) (string, []Parameter) {
+	var params []Parameter
+
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if len(segment) < 2 || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+
+		name := segment[1:]
+		segments[i] = fmt.Sprintf("{%s}", name)
+
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// jsonContent describes a JSON body with the schema.
+func jsonContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{
+		"application/json": {Schema: s},
+	}
+}
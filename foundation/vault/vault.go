@@ -0,0 +1,466 @@
+// Package vault provides support for using HashiCorp Vault as a KeyLookup for
+// private keys stored in the KV version 2 secrets engine.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNotFound is returned when a key can't be found in Vault.
+var ErrNotFound = errors.New("key not found")
+
+// Default location of the service account token inside a Kubernetes pod.
+const defaultK8sTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// staleRetry is how long a stale key is served before Vault is asked again,
+// after Vault couldn't be reached to refresh it.
+const staleRetry = 30 * time.Second
+
+// Logger is used to report the failures that don't fail a lookup, like a
+// stale key being served because Vault couldn't be reached. It matches the
+// Errorw method of a zap.SugaredLogger.
+type Logger func(msg string, keysAndValues ...any)
+
+// Config represents the mandatory settings needed to work with Vault. Either
+// a Token or a KubernetesRole must be provided for authentication.
+type Config struct {
+	Address        string
+	MountPath      string
+	Path           string
+	Token          string
+	KubernetesRole string
+	KubernetesAuth string
+	KubernetesJWT  string
+	CacheTTL       time.Duration
+	RequestTimeout time.Duration
+	Client         *http.Client
+	Log            Logger
+}
+
+// Vault provides support to access Hashicorp's Vault product for keys.
+type Vault struct {
+	address   string
+	mountPath string
+	path      string
+	k8sRole   string
+	k8sAuth   string
+	k8sJWT    string
+	cacheTTL  time.Duration
+	timeout   time.Duration
+	client    *http.Client
+	log       Logger
+
+	mu    sync.RWMutex
+	store map[string]cachedKey
+
+	authMu       sync.Mutex
+	token        string
+	tokenChecked bool
+	tokenExpire  time.Time
+	lease        time.Duration
+	renewable    bool
+}
+
+// cachedKey holds a parsed key and the time it must be fetched again.
+type cachedKey struct {
+	pk     *rsa.PrivateKey
+	pem    string
+	expire time.Time
+}
+
+// New constructs a vault for use.
+func New(cfg Config) (*Vault, error) {
+	if cfg.Token == "" && cfg.KubernetesRole == "" {
+		return nil, errors.New("a token or kubernetes role is required")
+	}
+
+	mountPath := cfg.MountPath
+	if mountPath == "" {
+		mountPath = "secret"
+	}
+
+	k8sAuth := cfg.KubernetesAuth
+	if k8sAuth == "" {
+		k8sAuth = "kubernetes"
+	}
+
+	k8sJWT := cfg.KubernetesJWT
+	if k8sJWT == "" {
+		k8sJWT = defaultK8sTokenFile
+	}
+
+	timeout := cfg.RequestTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	log := cfg.Log
+	if log == nil {
+		log = func(string, ...any) {}
+	}
+
+	v := Vault{
+		address:   strings.TrimSuffix(cfg.Address, "/"),
+		mountPath: strings.Trim(mountPath, "/"),
+		path:      strings.Trim(cfg.Path, "/"),
+		k8sRole:   cfg.KubernetesRole,
+		k8sAuth:   strings.Trim(k8sAuth, "/"),
+		k8sJWT:    k8sJWT,
+		cacheTTL:  cfg.CacheTTL,
+		timeout:   timeout,
+		client:    client,
+		log:       log,
+		token:     cfg.Token,
+		store:     make(map[string]cachedKey),
+	}
+
+	return &v, nil
+}
+
+// PrivateKey searches the key store for a given kid and returns the private key.
+func (v *Vault) PrivateKey(kid string) (string, error) {
+	key, err := v.lookup(kid)
+	if err != nil {
+		return "", err
+	}
+
+	return key.pem, nil
+}
+
+// PublicKey searches the key store for a given kid and returns the public key.
+func (v *Vault) PublicKey(kid string) (string, error) {
+	key, err := v.lookup(kid)
+	if err != nil {
+		return "", err
+	}
+
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(&key.pk.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	block := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+
+	var b bytes.Buffer
+	if err := pem.Encode(&b, &block); err != nil {
+		return "", fmt.Errorf("encoding to public file: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// AddPrivateKey adds a new private key into vault as a PEM under the kid.
+func (v *Vault) AddPrivateKey(ctx context.Context, kid string, pem []byte) error {
+	if _, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err != nil {
+		return fmt.Errorf("parsing private pem: %w", err)
+	}
+
+	body := struct {
+		Data map[string]string `json:"data"`
+	}{
+		Data: map[string]string{"pem": string(pem)},
+	}
+
+	if err := v.call(ctx, http.MethodPost, v.secretURL(kid), body, nil); err != nil {
+		return fmt.Errorf("writing key: %w", err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.store, kid)
+
+	return nil
+}
+
+// =============================================================================
+
+// lookup returns the key for the kid from the cache, fetching it from vault
+// when it isn't cached or the cached value is stale. When vault can't be
+// reached to refresh a stale key, the stale key is served for a while longer
+// and the failure is logged.
+func (v *Vault) lookup(kid string) (cachedKey, error) {
+	v.mu.RLock()
+	key, exists := v.store[kid]
+	v.mu.RUnlock()
+
+	if exists && (v.cacheTTL == 0 || time.Now().Before(key.expire)) {
+		return key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+
+	var resp struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := v.call(ctx, http.MethodGet, v.secretURL(kid), nil, &resp); err != nil {
+		if exists && !errors.Is(err, ErrNotFound) {
+			v.log("vault", "status", "serving stale key", "kid", kid, "ERROR", err)
+
+			key.expire = time.Now().Add(staleRetry)
+
+			v.mu.Lock()
+			defer v.mu.Unlock()
+			v.store[kid] = key
+
+			return key, nil
+		}
+		return cachedKey{}, fmt.Errorf("reading key: %w", err)
+	}
+
+	pemStr, exists := resp.Data.Data["pem"]
+	if !exists {
+		return cachedKey{}, ErrNotFound
+	}
+
+	pk, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(pemStr))
+	if err != nil {
+		return cachedKey{}, fmt.Errorf("parsing private pem: %w", err)
+	}
+
+	key = cachedKey{
+		pk:     pk,
+		pem:    pemStr,
+		expire: time.Now().Add(v.cacheTTL),
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.store[kid] = key
+
+	return key, nil
+}
+
+// secretURL returns the KV version 2 url for the specified kid. The kid is
+// escaped so it can't point at another secret of the mount.
+func (v *Vault) secretURL(kid string) string {
+	kid = url.PathEscape(kid)
+	if kid == "." || kid == ".." {
+		kid = strings.ReplaceAll(kid, ".", "%2E")
+	}
+	if v.path == "" {
+		return fmt.Sprintf("%s/v1/%s/data/%s", v.address, v.mountPath, kid)
+	}
+	return fmt.Sprintf("%s/v1/%s/data/%s/%s", v.address, v.mountPath, v.path, kid)
+}
+
+// call performs an authenticated request against the vault api.
+func (v *Vault) call(ctx context.Context, method string, endpoint string, body any, dest any) error {
+	token, err := v.authToken(ctx)
+	if err != nil {
+		return fmt.Errorf("authenticating: %w", err)
+	}
+
+	return v.do(ctx, method, endpoint, token, body, dest)
+}
+
+// do performs the request against the vault api and decodes the response.
+func (v *Vault) do(ctx context.Context, method string, endpoint string, token string, body any, dest any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal body: %w", err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, r)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		var errs struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&errs)
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, strings.Join(errs.Errors, ", "))
+	}
+
+	if dest == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dest); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	return nil
+}
+
+// =============================================================================
+
+// authResponse is the auth section returned by login and renew calls.
+type authResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// authToken returns a valid token for calling vault. The lease of a token is
+// renewed once half of it has passed. When the token can't be renewed and the
+// kubernetes method is configured, the service logs in again.
+func (v *Vault) authToken(ctx context.Context) (string, error) {
+	v.authMu.Lock()
+	defer v.authMu.Unlock()
+
+	// A token that can't be looked up is dropped when the kubernetes method
+	// is configured, so the service logs in instead of using a token whose
+	// lease is unknown.
+	if v.token != "" && !v.tokenChecked {
+		if err := v.lookupSelf(ctx); err != nil {
+			if v.k8sRole == "" {
+				return "", err
+			}
+			v.token = ""
+		}
+	}
+
+	now := time.Now()
+
+	if v.token != "" && (v.tokenExpire.IsZero() || v.tokenExpire.Sub(now) > v.lease/2) {
+		return v.token, nil
+	}
+
+	if v.token != "" && v.renewable && now.Before(v.tokenExpire) {
+		if err := v.renew(ctx); err == nil {
+			return v.token, nil
+		}
+	}
+
+	if v.k8sRole != "" {
+		if err := v.loginKubernetes(ctx); err != nil {
+			return "", err
+		}
+		return v.token, nil
+	}
+
+	if v.token == "" {
+		return "", errors.New("no token available")
+	}
+
+	return v.token, nil
+}
+
+// lookupSelf retrieves the lease details of a configured token.
+func (v *Vault) lookupSelf(ctx context.Context) error {
+	var resp struct {
+		Data struct {
+			TTL       int  `json:"ttl"`
+			Renewable bool `json:"renewable"`
+		} `json:"data"`
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/auth/token/lookup-self", v.address)
+	if err := v.do(ctx, http.MethodGet, endpoint, v.token, nil, &resp); err != nil {
+		return fmt.Errorf("lookup token: %w", err)
+	}
+
+	v.tokenChecked = true
+	v.setLease(resp.Data.TTL, resp.Data.Renewable)
+
+	return nil
+}
+
+// renew extends the lease of the current token.
+func (v *Vault) renew(ctx context.Context) error {
+	var resp authResponse
+	endpoint := fmt.Sprintf("%s/v1/auth/token/renew-self", v.address)
+	if err := v.do(ctx, http.MethodPost, endpoint, v.token, struct{}{}, &resp); err != nil {
+		return fmt.Errorf("renew token: %w", err)
+	}
+
+	v.setLease(resp.Auth.LeaseDuration, resp.Auth.Renewable)
+
+	return nil
+}
+
+// loginKubernetes authenticates with the service account token of the pod.
+func (v *Vault) loginKubernetes(ctx context.Context) error {
+	saToken, err := os.ReadFile(v.k8sJWT)
+	if err != nil {
+		return fmt.Errorf("reading service account token: %w", err)
+	}
+
+	body := struct {
+		Role string `json:"role"`
+		JWT  string `json:"jwt"`
+	}{
+		Role: v.k8sRole,
+		JWT:  strings.TrimSpace(string(saToken)),
+	}
+
+	var resp authResponse
+	endpoint := fmt.Sprintf("%s/v1/auth/%s/login", v.address, v.k8sAuth)
+	if err := v.do(ctx, http.MethodPost, endpoint, "", body, &resp); err != nil {
+		return fmt.Errorf("kubernetes login: %w", err)
+	}
+
+	if resp.Auth.ClientToken == "" {
+		return errors.New("kubernetes login: no token returned")
+	}
+
+	v.token = resp.Auth.ClientToken
+	v.tokenChecked = true
+	v.setLease(resp.Auth.LeaseDuration, resp.Auth.Renewable)
+
+	return nil
+}
+
+// setLease stores the details of the lease for the current token. A lease
+// duration of zero means the token doesn't expire.
+func (v *Vault) setLease(seconds int, renewable bool) {
+	v.lease = time.Duration(seconds) * time.Second
+	v.renewable = renewable
+
+	v.tokenExpire = time.Time{}
+	if seconds > 0 {
+		v.tokenExpire = time.Now().Add(v.lease)
+	}
+}
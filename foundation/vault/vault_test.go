@@ -0,0 +1,351 @@
+package vault
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server is a fake vault that serves a single key and records the calls.
+type server struct {
+	t   *testing.T
+	pem string
+
+	mu       sync.Mutex
+	calls    []string
+	tokens   []string
+	down     bool
+	lookupOK bool
+	login    struct{ Role, JWT string }
+}
+
+func (s *server) called() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.calls...)
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = append(s.calls, r.Method+" "+r.URL.EscapedPath())
+	s.tokens = append(s.tokens, r.Header.Get("X-Vault-Token"))
+
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{"sealed"}})
+		return
+	}
+
+	switch r.URL.EscapedPath() {
+	case "/v1/auth/token/lookup-self":
+		if !s.lookupOK {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"ttl": 3600, "renewable": true},
+		})
+
+	case "/v1/auth/token/renew-self":
+		json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{"client_token": "static", "lease_duration": 7200, "renewable": true},
+		})
+
+	case "/v1/auth/kubernetes/login":
+		if err := json.NewDecoder(r.Body).Decode(&s.login); err != nil {
+			s.t.Errorf("decoding login body: %s", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{"client_token": "k8s-token", "lease_duration": 3600, "renewable": true},
+		})
+
+	case "/v1/secret/data/keys/kid1":
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"data": map[string]string{"pem": s.pem}},
+		})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newServer(t *testing.T) (*server, *httptest.Server) {
+	t.Helper()
+
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %s", err)
+	}
+
+	block := pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(pk),
+	}
+
+	s := server{
+		t:        t,
+		pem:      string(pem.EncodeToMemory(&block)),
+		lookupOK: true,
+	}
+
+	srv := httptest.NewServer(&s)
+	t.Cleanup(srv.Close)
+
+	return &s, srv
+}
+
+// =============================================================================
+
+func TestToken(t *testing.T) {
+	s, srv := newServer(t)
+
+	v, err := New(Config{
+		Address: srv.URL,
+		Path:    "keys",
+		Token:   "static",
+	})
+	if err != nil {
+		t.Fatalf("constructing vault: %s", err)
+	}
+
+	got, err := v.PrivateKey("kid1")
+	if err != nil {
+		t.Fatalf("looking up key: %s", err)
+	}
+	if got != s.pem {
+		t.Fatalf("got pem %q, exp %q", got, s.pem)
+	}
+
+	exp := []string{"GET /v1/auth/token/lookup-self", "GET /v1/secret/data/keys/kid1"}
+	if calls := s.called(); !equal(calls, exp) {
+		t.Fatalf("got calls %v, exp %v", calls, exp)
+	}
+
+	for i, token := range s.tokens {
+		if token != "static" {
+			t.Errorf("call %d: got token %q, exp %q", i, token, "static")
+		}
+	}
+
+	if _, err := v.PublicKey("kid1"); err != nil {
+		t.Fatalf("looking up public key: %s", err)
+	}
+	if calls := s.called(); len(calls) != len(exp) {
+		t.Fatalf("cached key fetched again: %v", calls)
+	}
+
+	if _, err := v.PrivateKey("missing"); err == nil {
+		t.Fatal("expected an error for a missing key")
+	}
+}
+
+func TestRenew(t *testing.T) {
+	s, srv := newServer(t)
+
+	v, err := New(Config{
+		Address: srv.URL,
+		Path:    "keys",
+		Token:   "static",
+	})
+	if err != nil {
+		t.Fatalf("constructing vault: %s", err)
+	}
+
+	if _, err := v.PrivateKey("kid1"); err != nil {
+		t.Fatalf("looking up key: %s", err)
+	}
+
+	// Move the token past half of its lease and force the key to be read
+	// again, the token must be renewed before the read.
+	v.authMu.Lock()
+	v.tokenExpire = time.Now().Add(v.lease / 4)
+	v.authMu.Unlock()
+
+	v.mu.Lock()
+	delete(v.store, "kid1")
+	v.mu.Unlock()
+
+	if _, err := v.PrivateKey("kid1"); err != nil {
+		t.Fatalf("looking up key: %s", err)
+	}
+
+	calls := s.called()
+	exp := []string{"POST /v1/auth/token/renew-self", "GET /v1/secret/data/keys/kid1"}
+	if got := calls[len(calls)-2:]; !equal(got, exp) {
+		t.Fatalf("got calls %v, exp %v", got, exp)
+	}
+
+	if v.lease != 7200*time.Second {
+		t.Fatalf("got lease %v, exp %v", v.lease, 7200*time.Second)
+	}
+}
+
+func TestKubernetesLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		lookupOK bool
+		exp      []string
+	}{
+		{
+			name: "no token",
+			exp: []string{
+				"POST /v1/auth/kubernetes/login",
+				"GET /v1/secret/data/keys/kid1",
+			},
+		},
+		{
+			name:  "token failing lookup",
+			token: "revoked",
+			exp: []string{
+				"GET /v1/auth/token/lookup-self",
+				"POST /v1/auth/kubernetes/login",
+				"GET /v1/secret/data/keys/kid1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, srv := newServer(t)
+			s.lookupOK = tt.lookupOK
+
+			jwtFile := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(jwtFile, []byte("sa-jwt\n"), 0600); err != nil {
+				t.Fatalf("writing service account token: %s", err)
+			}
+
+			v, err := New(Config{
+				Address:        srv.URL,
+				Path:           "keys",
+				Token:          tt.token,
+				KubernetesRole: "sales",
+				KubernetesJWT:  jwtFile,
+			})
+			if err != nil {
+				t.Fatalf("constructing vault: %s", err)
+			}
+
+			if _, err := v.PrivateKey("kid1"); err != nil {
+				t.Fatalf("looking up key: %s", err)
+			}
+
+			if calls := s.called(); !equal(calls, tt.exp) {
+				t.Fatalf("got calls %v, exp %v", calls, tt.exp)
+			}
+
+			if s.login.Role != "sales" || s.login.JWT != "sa-jwt" {
+				t.Fatalf("got login %+v, exp role %q and jwt %q", s.login, "sales", "sa-jwt")
+			}
+
+			if token := s.tokens[len(s.tokens)-1]; token != "k8s-token" {
+				t.Fatalf("got token %q, exp %q", token, "k8s-token")
+			}
+		})
+	}
+}
+
+func TestStaleKey(t *testing.T) {
+	s, srv := newServer(t)
+
+	var logged []any
+	v, err := New(Config{
+		Address:  srv.URL,
+		Path:     "keys",
+		Token:    "static",
+		CacheTTL: time.Minute,
+		Log: func(msg string, keysAndValues ...any) {
+			logged = append(logged, keysAndValues...)
+		},
+	})
+	if err != nil {
+		t.Fatalf("constructing vault: %s", err)
+	}
+
+	if _, err := v.PrivateKey("kid1"); err != nil {
+		t.Fatalf("looking up key: %s", err)
+	}
+
+	v.mu.Lock()
+	key := v.store["kid1"]
+	key.expire = time.Now().Add(-time.Second)
+	v.store["kid1"] = key
+	v.mu.Unlock()
+
+	s.mu.Lock()
+	s.down = true
+	s.mu.Unlock()
+
+	got, err := v.PrivateKey("kid1")
+	if err != nil {
+		t.Fatalf("expected the stale key to be served: %s", err)
+	}
+	if got != s.pem {
+		t.Fatalf("got pem %q, exp %q", got, s.pem)
+	}
+
+	if len(logged) == 0 {
+		t.Fatal("expected the failure to be logged")
+	}
+
+	if _, err := v.PrivateKey("kid2"); err == nil {
+		t.Fatal("expected an error for a key that was never cached")
+	}
+}
+
+func TestSecretURL(t *testing.T) {
+	tests := []struct {
+		path string
+		kid  string
+		exp  string
+	}{
+		{path: "keys", kid: "kid1", exp: "http://vault/v1/secret/data/keys/kid1"},
+		{path: "", kid: "kid1", exp: "http://vault/v1/secret/data/kid1"},
+		{path: "keys", kid: "../other", exp: "http://vault/v1/secret/data/keys/..%2Fother"},
+		{path: "keys", kid: "a/b", exp: "http://vault/v1/secret/data/keys/a%2Fb"},
+		{path: "keys", kid: "..", exp: "http://vault/v1/secret/data/keys/%2E%2E"},
+		{path: "keys", kid: ".", exp: "http://vault/v1/secret/data/keys/%2E"},
+		{path: "keys", kid: "a?b#c", exp: "http://vault/v1/secret/data/keys/a%3Fb%23c"},
+	}
+
+	for _, tt := range tests {
+		v, err := New(Config{
+			Address: "http://vault/",
+			Path:    tt.path,
+			Token:   "static",
+		})
+		if err != nil {
+			t.Fatalf("constructing vault: %s", err)
+		}
+
+		if got := v.secretURL(tt.kid); got != tt.exp {
+			t.Errorf("kid %q: got %q, exp %q", tt.kid, got, tt.exp)
+		}
+	}
+}
+
+// =============================================================================
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
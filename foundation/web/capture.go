@@ -0,0 +1,89 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// CaptureWriter is a http.ResponseWriter that passes the response through to
+// the client while keeping a copy of its status code, headers and body, so
+// it can be stored and replayed later. Only the first limit bytes of the body
+// are kept.
+type CaptureWriter struct {
+	http.ResponseWriter
+	statusCode int
+	header     http.Header
+	body       bytes.Buffer
+	limit      int
+	truncated  bool
+}
+
+// NewCaptureWriter constructs a writer capturing the response written to w.
+func NewCaptureWriter(w http.ResponseWriter, limit int) *CaptureWriter {
+	return &CaptureWriter{
+		ResponseWriter: w,
+		limit:          limit,
+	}
+}
+
+// WriteHeader implements the http.ResponseWriter interface. The headers are
+// captured as they are when the status code is sent.
+func (cw *CaptureWriter) WriteHeader(statusCode int) {
+	if cw.statusCode == 0 {
+		cw.statusCode = statusCode
+		cw.header = cw.ResponseWriter.Header().Clone()
+	}
+
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements the http.ResponseWriter interface.
+func (cw *CaptureWriter) Write(p []byte) (int, error) {
+	if cw.statusCode == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	switch {
+	case cw.body.Len()+len(p) <= cw.limit:
+		cw.body.Write(p)
+	default:
+		cw.truncated = true
+	}
+
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush implements the http.Flusher interface when the wrapped writer does.
+func (cw *CaptureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer for use by http.ResponseController.
+func (cw *CaptureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// StatusCode returns the status code sent to the client, or zero when nothing
+// has been written.
+func (cw *CaptureWriter) StatusCode() int {
+	return cw.statusCode
+}
+
+// Header returns the header map of the wrapped writer. Use CapturedHeader
+// for the headers as they were sent.
+func (cw *CaptureWriter) Header() http.Header {
+	return cw.ResponseWriter.Header()
+}
+
+// CapturedHeader returns the headers sent to the client.
+func (cw *CaptureWriter) CapturedHeader() http.Header {
+	return cw.header
+}
+
+// Body returns the captured body. False is returned when the body was larger
+// than the limit and only part of it was captured.
+func (cw *CaptureWriter) Body() ([]byte, bool) {
+	return cw.body.Bytes(), !cw.truncated
+}
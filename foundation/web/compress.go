@@ -0,0 +1,134 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Set of content encodings that can be used for responses.
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// The writers are expensive to allocate so they are reused between responses.
+var (
+	gzipPool = sync.Pool{
+		New: func() any { return gzip.NewWriter(io.Discard) },
+	}
+	zlibPool = sync.Pool{
+		New: func() any { return zlib.NewWriter(io.Discard) },
+	}
+)
+
+// EnableCompression compresses the responses of at least minSize bytes when
+// the client accepts it. It must be called before the app serves requests.
+func (a *App) EnableCompression(minSize int) {
+	if minSize < 1 {
+		minSize = 1
+	}
+
+	a.compressMin = minSize
+}
+
+// DisableCompression turns off the compression of the response for the
+// request in the context. Handlers that stream their response or send content
+// that is already compressed use this to opt out.
+func DisableCompression(ctx context.Context) {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok {
+		return
+	}
+
+	v.encoding = ""
+}
+
+// negotiateEncoding selects the encoding for the response from the
+// Accept-Encoding header. Gzip is preferred when both are equally acceptable
+// and an empty string means the response must not be compressed.
+// https://www.rfc-editor.org/rfc/rfc9110#name-accept-encoding
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+
+		switch name {
+		case "*":
+			wildcard = q
+		case EncodingGzip, EncodingDeflate:
+			accepted[name] = q
+		}
+	}
+
+	var encoding string
+	var best float64
+	for _, name := range []string{EncodingGzip, EncodingDeflate} {
+		q, exists := accepted[name]
+		if !exists {
+			q = wildcard
+		}
+
+		if q > best {
+			encoding, best = name, q
+		}
+	}
+
+	return encoding
+}
+
+// compress encodes the data using the specified encoding.
+func compress(encoding string, data []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	zw, release, err := newCompressor(encoding, &b)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// newCompressor returns a writer that compresses into w using the specified
+// encoding and the function to call once the writer is closed.
+func newCompressor(encoding string, w io.Writer) (io.WriteCloser, func(), error) {
+	switch encoding {
+	case EncodingGzip:
+		zw := gzipPool.Get().(*gzip.Writer)
+		zw.Reset(w)
+		return zw, func() { gzipPool.Put(zw) }, nil
+
+	case EncodingDeflate:
+		zw := zlibPool.Get().(*zlib.Writer)
+		zw.Reset(w)
+		return zw, func() { zlibPool.Put(zw) }, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported encoding %q", encoding)
+}
@@ -3,6 +3,8 @@ package web
 import (
 	"context"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type ctxKey int
@@ -12,8 +14,17 @@ const key ctxKey = 1
 // Values represent state for each request.
 type Values struct {
 	TraceID    string
+	SpanID     string
+	TraceFlags string
+	TraceState string
+	Tracer     trace.Tracer
 	Now        time.Time
 	StatusCode int
+
+	encoder       Encoder
+	notAcceptable bool
+	encoding      string
+	compressMin   int
 }
 
 // SetValues sets the specified Values in the context.
@@ -0,0 +1,385 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnsupportedValue is returned by an encoder for a value it can't represent.
+// The response then falls back to JSON.
+var ErrUnsupportedValue = errors.New("value can't be encoded in this format")
+
+// ErrNotAcceptable is returned by Respond when none of the registered
+// encoders is acceptable to the client.
+var ErrNotAcceptable = errors.New("response can't be sent in any of the acceptable media types")
+
+// FormatParam is the query parameter used to select an encoder by its format,
+// which takes precedence over the Accept header.
+const FormatParam = "format"
+
+// Encoder represents a format responses can be encoded in. A Stream encoder
+// writes straight to the client instead of into a buffer first, so it must
+// return ErrUnsupportedValue before it writes anything. Clients accepting one
+// of the Aliases are served by the encoder as well.
+type Encoder struct {
+	ContentType string
+	Format      string
+	Stream      bool
+	Aliases     []string
+	Encode      func(w io.Writer, data any) error
+}
+
+// jsonEncoder is the default encoder for responses. Clients that only ask for
+// problem details, which are JSON, are served JSON too.
+var jsonEncoder = Encoder{
+	ContentType: "application/json",
+	Format:      "json",
+	Aliases:     []string{"application/problem+json"},
+	Encode:      encodeJSON,
+}
+
+// encoders is the registry of encoders in order of preference.
+var encoders = struct {
+	mu   sync.RWMutex
+	list []Encoder
+}{
+	list: []Encoder{
+		jsonEncoder,
+		{ContentType: "application/xml", Format: "xml", Encode: encodeXML},
+		{ContentType: "text/csv; charset=utf-8", Format: "csv", Stream: true, Encode: encodeCSV},
+	},
+}
+
+// RegisterEncoder adds the encoder to the registry, replacing any encoder
+// registered for the same content type.
+func RegisterEncoder(enc Encoder) {
+	encoders.mu.Lock()
+	defer encoders.mu.Unlock()
+
+	for i, e := range encoders.list {
+		if mediaType(e.ContentType) == mediaType(enc.ContentType) {
+			encoders.list[i] = enc
+			return
+		}
+	}
+
+	encoders.list = append(encoders.list, enc)
+}
+
+// negotiateEncoder selects the encoder for the response from the format query
+// parameter or the Accept header. False is returned when no registered
+// encoder is acceptable to the client.
+// https://www.rfc-editor.org/rfc/rfc9110#name-accept
+func negotiateEncoder(r *http.Request) (Encoder, bool) {
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+
+	if format := r.URL.Query().Get(FormatParam); format != "" {
+		for _, enc := range encoders.list {
+			if strings.EqualFold(enc.Format, format) {
+				return enc, true
+			}
+		}
+		return Encoder{}, false
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return encoders.list[0], true
+	}
+
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	var best Encoder
+	var bestQ float64
+	for _, enc := range encoders.list {
+		q := acceptQuality(ranges, mediaType(enc.ContentType))
+		for _, alias := range enc.Aliases {
+			q = max(q, acceptQuality(ranges, mediaType(alias)))
+		}
+
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+
+	return best, bestQ > 0
+}
+
+// AcceptQuality returns the quality the client gives the media type in its
+// Accept header. A request without an Accept header accepts any media type.
+func AcceptQuality(r *http.Request, media string) float64 {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return 1
+	}
+
+	return acceptQuality(parseAccept(strings.Join(accept, ",")), strings.ToLower(media))
+}
+
+// AcceptsExplicitly reports whether the client names the media type itself in
+// its Accept header, rather than accepting it through a wildcard.
+func AcceptsExplicitly(r *http.Request, media string) bool {
+	media = strings.ToLower(media)
+	for _, mr := range parseAccept(strings.Join(r.Header.Values("Accept"), ",")) {
+		if mr.typ+"/"+mr.sub == media && mr.q > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// =============================================================================
+
+// mediaRange represents one of the media ranges of an Accept header.
+type mediaRange struct {
+	typ string
+	sub string
+	q   float64
+}
+
+// parseAccept parses the media ranges and their quality from an Accept header.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		media, params, _ := strings.Cut(part, ";")
+		media = strings.ToLower(strings.TrimSpace(media))
+		if media == "*" {
+			media = "*/*"
+		}
+
+		typ, sub, ok := strings.Cut(media, "/")
+		if !ok {
+			continue
+		}
+
+		mr := mediaRange{typ: typ, sub: sub, q: 1}
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(k) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				mr.q = q
+			}
+		}
+
+		ranges = append(ranges, mr)
+	}
+
+	return ranges
+}
+
+// acceptQuality returns the quality the client gives the media type, using the
+// most specific media range that matches it.
+func acceptQuality(ranges []mediaRange, media string) float64 {
+	typ, sub, _ := strings.Cut(media, "/")
+
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		var s int
+		switch {
+		case mr.typ == typ && mr.sub == sub:
+			s = 2
+		case mr.typ == typ && mr.sub == "*":
+			s = 1
+		case mr.typ == "*" && mr.sub == "*":
+			s = 0
+		default:
+			continue
+		}
+
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+
+	return q
+}
+
+// mediaType returns the content type without its parameters.
+func mediaType(contentType string) string {
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(contentType)
+	}
+
+	return media
+}
+
+// =============================================================================
+
+// encodeJSON encodes the value as JSON.
+func encodeJSON(w io.Writer, data any) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(jsonData)
+	return err
+}
+
+// encodeXML encodes the value as XML. Slices are wrapped in an items element
+// so the document has a single root.
+func encodeXML(w io.Writer, data any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+
+	err := func() error {
+		rv := reflect.Indirect(reflect.ValueOf(data))
+		if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+			return enc.Encode(data)
+		}
+
+		start := xml.StartElement{Name: xml.Name{Local: "items"}}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		if err := enc.EncodeToken(start.End()); err != nil {
+			return err
+		}
+
+		return enc.Flush()
+	}()
+
+	var ute *xml.UnsupportedTypeError
+	if errors.As(err, &ute) {
+		return fmt.Errorf("%w: %s", ErrUnsupportedValue, err)
+	}
+
+	return err
+}
+
+// encodeCSV encodes a slice of structs as CSV with a header row. The column
+// names come from the csv struct tag, then the json struct tag, then the
+// field name. Fields tagged with "-" are left out.
+func encodeCSV(w io.Writer, data any) error {
+	rv := reflect.ValueOf(data)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return ErrUnsupportedValue
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return ErrUnsupportedValue
+	}
+
+	elem := rv.Type().Elem()
+	for elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+
+	if elem.Kind() != reflect.Struct {
+		return ErrUnsupportedValue
+	}
+
+	type column struct {
+		name  string
+		index []int
+	}
+
+	var columns []column
+	for _, f := range reflect.VisibleFields(elem) {
+		if !f.IsExported() || (f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("csv"); ok {
+			name, _, _ = strings.Cut(tag, ",")
+		} else if tag, ok := f.Tag.Lookup("json"); ok {
+			if n, _, _ := strings.Cut(tag, ","); n != "" {
+				name = n
+			}
+		}
+
+		if name == "-" {
+			continue
+		}
+
+		columns = append(columns, column{name: name, index: f.Index})
+	}
+
+	cw := csv.NewWriter(w)
+
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		row := reflect.Indirect(rv.Index(i))
+
+		for j, c := range columns {
+			record[j] = ""
+			if row.IsValid() {
+				if fv, err := row.FieldByIndexErr(c.index); err == nil {
+					record[j] = csvValue(fv)
+				}
+			}
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvValue formats the value of a field for a CSV cell. Slices are joined
+// with semicolons.
+func csvValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case fmt.Stringer:
+		return val.String()
+	case []byte:
+		return string(val)
+	}
+
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = csvValue(v.Index(i))
+		}
+		return strings.Join(items, ";")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
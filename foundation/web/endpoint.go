@@ -0,0 +1,77 @@
+package web
+
+import "sync"
+
+// Endpoint represents a route registered on the app and the metadata that
+// documents it.
+type Endpoint struct {
+	Method     string
+	Path       string
+	Deprecated bool
+	Doc        EndpointDoc
+}
+
+// EndpointDoc represents the metadata documenting a route. Request and
+// Response are values of the models decoded from the body and sent back,
+// Status is the status code of a successful response and Auth is the
+// authorization rule the caller must pass. An empty Auth means the route is
+// public.
+type EndpointDoc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Request     any
+	Response    any
+	Status      int
+	Auth        string
+	Query       []QueryParam
+}
+
+// QueryParam represents a query parameter accepted by a route. Type is the
+// JSON schema type of the value and defaults to string.
+type QueryParam struct {
+	Name        string
+	Description string
+	Type        string
+	Required    bool
+}
+
+// Describe sets the metadata documenting the endpoint.
+func (e *Endpoint) Describe(doc EndpointDoc) *Endpoint {
+	e.Doc = doc
+	return e
+}
+
+// endpoints is the set of endpoints registered on an app.
+type endpoints struct {
+	mu   sync.RWMutex
+	list []*Endpoint
+}
+
+// add records a new endpoint.
+func (es *endpoints) add(method string, path string) *Endpoint {
+	e := Endpoint{
+		Method: method,
+		Path:   path,
+	}
+
+	es.mu.Lock()
+	es.list = append(es.list, &e)
+	es.mu.Unlock()
+
+	return &e
+}
+
+// Endpoints returns a copy of the endpoints registered on the app in the
+// order they were registered.
+func (a *App) Endpoints() []Endpoint {
+	a.endpoints.mu.RLock()
+	defer a.endpoints.mu.RUnlock()
+
+	list := make([]Endpoint, len(a.endpoints.list))
+	for i, e := range a.endpoints.list {
+		list[i] = *e
+	}
+
+	return list
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrPreconditionFailed is returned when the If-Match header of a request
+// doesn't match the current version of the resource.
+var ErrPreconditionFailed = errors.New("resource was modified since it was read")
+
+// ETag constructs the entity tag for a version of a resource. The tag is weak,
+// since the JSON, XML and CSV representations of the version and their gzip
+// and deflate encodings all share it.
+func ETag(version int) string {
+	return "W/" + strconv.Quote(strconv.Itoa(version))
+}
+
+// SetETag sets the entity tag of the resource on the response.
+func SetETag(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+}
+
+// CheckIfMatch checks the If-Match header of a request against the entity tag
+// of the current version of the resource. Requests without the header are
+// allowed, otherwise ErrPreconditionFailed is returned on a mismatch. The tags
+// are compared weakly: a write only depends on the version the client read,
+// not on the representation it was sent.
+// https://www.rfc-editor.org/rfc/rfc9110#name-if-match
+func CheckIfMatch(r *http.Request, etag string) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+
+	if !matchETag(header, etag) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// NotModified checks the If-None-Match header of a GET or HEAD request against
+// the entity tag of the current version of the resource. When the client
+// already has that version, a 304 response is sent and true is returned so
+// the handler can stop.
+// https://www.rfc-editor.org/rfc/rfc9110#name-if-none-match
+func NotModified(ctx context.Context, w http.ResponseWriter, r *http.Request, etag string) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !matchETag(header, etag) {
+		return false
+	}
+
+	SetETag(w, etag)
+	SetStatusCode(ctx, http.StatusNotModified)
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// matchETag reports if the list of entity tags in the header matches the
+// entity tag using the weak comparison, which ignores the W/ prefix.
+func matchETag(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
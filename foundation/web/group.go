@@ -0,0 +1,119 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Group represents a set of routes sharing a path prefix and middleware, like
+// the routes for one version of the api. The global middleware of the app
+// runs first, then the middleware of the group and then the middleware of
+// the route.
+type Group struct {
+	app         *App
+	prefix      string
+	mw          []Middleware
+	deprecation *Deprecation
+}
+
+// Group constructs a group of routes under the path prefix.
+func (a *App) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		app:    a,
+		prefix: strings.TrimSuffix(prefix, "/"),
+		mw:     mw,
+	}
+}
+
+// Group constructs a group of routes nested under the group. The nested group
+// inherits the middleware and deprecation of the group.
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{
+		app:         g.app,
+		prefix:      g.prefix + strings.TrimSuffix(prefix, "/"),
+		mw:          append(append([]Middleware{}, g.mw...), mw...),
+		deprecation: g.deprecation,
+	}
+}
+
+// Handle sets a handler function for a given HTTP method and path pair
+// relative to the prefix of the group. The returned endpoint can be used to
+// document the route.
+func (g *Group) Handle(method string, path string, handler Handler, mw ...Middleware) *Endpoint {
+	mw = append(append([]Middleware{}, g.mw...), mw...)
+
+	if g.deprecation != nil {
+		mw = append([]Middleware{g.deprecation.middleware()}, mw...)
+	}
+
+	e := g.app.Handle(method, g.prefix+path, handler, mw...)
+	e.Deprecated = g.deprecation != nil
+
+	return e
+}
+
+// =============================================================================
+
+// Deprecation describes the retirement of a group of routes. Date is when the
+// routes were deprecated, Sunset is when they will stop responding and Link
+// is a page documenting how to migrate. Any of them can be left empty.
+type Deprecation struct {
+	Date   time.Time
+	Sunset time.Time
+	Link   string
+}
+
+// Deprecate marks every route of the group as deprecated so the responses
+// carry the Deprecation, Sunset and Link headers. It must be called before
+// the routes of the group are registered.
+// https://www.rfc-editor.org/rfc/rfc9745
+// https://www.rfc-editor.org/rfc/rfc8594
+func (g *Group) Deprecate(d Deprecation) *Group {
+	g.deprecation = &d
+	return g
+}
+
+// middleware constructs the middleware setting the deprecation headers.
+func (d *Deprecation) middleware() Middleware {
+	deprecation := "@" + fmt.Sprint(time.Now().Unix())
+	if !d.Date.IsZero() {
+		deprecation = "@" + fmt.Sprint(d.Date.Unix())
+	}
+
+	var sunset string
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+
+	var link string
+	if d.Link != "" {
+		link = fmt.Sprintf("<%s>; rel=\"deprecation\"; type=\"text/html\"", d.Link)
+		if sunset != "" {
+			link += fmt.Sprintf(", <%s>; rel=\"sunset\"; type=\"text/html\"", d.Link)
+		}
+	}
+
+	m := func(handler Handler) Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			hdr := w.Header()
+			hdr.Set("Deprecation", deprecation)
+
+			if sunset != "" {
+				hdr.Set("Sunset", sunset)
+			}
+
+			if link != "" {
+				hdr.Add("Link", link)
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,109 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// connKey is the context key of the connection a request arrived on.
+const connKey ctxKey = 2
+
+// H2C serves HTTP/2 in clear text beside HTTP/1 on a server. The h2c
+// connections are hijacked from the server, so its Shutdown neither waits for
+// them nor closes them. The Shutdown of H2C does, and is called after it.
+type H2C struct {
+	handler http.Handler
+	mu      sync.Mutex
+	conns   map[net.Conn]int
+}
+
+// NewH2C configures the server to accept h2c connections for its handler.
+// The server also sends a GOAWAY to the h2c connections on Shutdown, so the
+// clients stop opening streams on them.
+func NewH2C(srv *http.Server, h2s *http2.Server) (*H2C, error) {
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		return nil, fmt.Errorf("configuring http/2: %w", err)
+	}
+
+	s := H2C{
+		handler: h2c.NewHandler(srv.Handler, h2s),
+		conns:   make(map[net.Conn]int),
+	}
+
+	connContext := srv.ConnContext
+	srv.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if connContext != nil {
+			ctx = connContext(ctx, c)
+		}
+		return context.WithValue(ctx, connKey, c)
+	}
+
+	srv.Handler = &s
+
+	return &s, nil
+}
+
+// ServeHTTP tracks the connection of the request while it's served. An h2c
+// connection is served until it's closed, so it's tracked for as long.
+func (s *H2C) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c, ok := r.Context().Value(connKey).(net.Conn); ok {
+		s.track(c, 1)
+		defer s.track(c, -1)
+	}
+
+	s.handler.ServeHTTP(w, r)
+}
+
+// Shutdown waits for the connections to finish the streams in flight and
+// close. The connections still open when the context is done are closed.
+func (s *H2C) Shutdown(ctx context.Context) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if s.active() == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the connections immediately.
+func (s *H2C) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+func (s *H2C) track(c net.Conn, delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conns[c] += delta
+	if s.conns[c] <= 0 {
+		delete(s.conns, c)
+	}
+}
+
+func (s *H2C) active() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conns)
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Set of errors for requests the app gave up on.
+var (
+	ErrTimeout    = errors.New("request timed out")
+	ErrOverloaded = errors.New("server is overloaded")
+)
+
+// Timeouts represents the deadlines applied to the context of the handlers.
+// The Routes deadlines are keyed by the method and route pattern, like
+// "GET /v1/users", and override the Default deadline. A zero duration means
+// no deadline.
+type Timeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// EnableTimeouts applies the deadlines to the context of every handler. A
+// handler that fails once its deadline has passed returns an error wrapping
+// ErrTimeout. Handlers must honor the context for the deadline to stop them.
+// It must be called before any route is registered.
+func (a *App) EnableTimeouts(t Timeouts) {
+	routes := make(map[string]time.Duration, len(t.Routes))
+	for k, d := range t.Routes {
+		method, route, _ := strings.Cut(k, " ")
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(route)] = d
+	}
+
+	m := func(handler Handler) Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			d, exists := routes[r.Method+" "+Route(r)]
+			if !exists {
+				d = t.Default
+			}
+
+			if d <= 0 {
+				return handler(ctx, w, r)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			err := handler(ctx, w, r.WithContext(ctx))
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, ErrTimeout) {
+				return fmt.Errorf("%w: %w", ErrTimeout, err)
+			}
+
+			return err
+		}
+
+		return h
+	}
+
+	a.mw = append(a.mw, m)
+}
+
+// LoadShedding represents the limits on the number of requests handled at the
+// same time. The Streams routes, like "GET /v1/events", hold their request
+// for as long as the client listens, so they are counted against MaxStreams
+// instead of MaxInFlight. A limit below one means no limit.
+type LoadShedding struct {
+	MaxInFlight int
+	MaxStreams  int
+	Streams     []string
+	RetryAfter  time.Duration
+}
+
+// EnableLoadShedding limits the number of requests handled at the same time.
+// Requests over the limit are rejected right away with ErrOverloaded and a
+// Retry-After header, instead of queueing behind the requests in flight. It
+// must be called before any route is registered.
+func (a *App) EnableLoadShedding(ls LoadShedding) {
+	if ls.MaxInFlight < 1 && ls.MaxStreams < 1 {
+		return
+	}
+
+	streams := make(map[string]bool, len(ls.Streams))
+	for _, k := range ls.Streams {
+		method, route, _ := strings.Cut(strings.TrimSpace(k), " ")
+		streams[strings.ToUpper(method)+" "+strings.TrimSpace(route)] = true
+	}
+
+	var sem, streamSem chan struct{}
+	if ls.MaxInFlight > 0 {
+		sem = make(chan struct{}, ls.MaxInFlight)
+	}
+	if ls.MaxStreams > 0 {
+		streamSem = make(chan struct{}, ls.MaxStreams)
+	}
+
+	retry := strconv.Itoa(int(math.Ceil(ls.RetryAfter.Seconds())))
+
+	m := func(handler Handler) Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			s := sem
+			if streams[r.Method+" "+Route(r)] {
+				s = streamSem
+			}
+
+			if s == nil {
+				return handler(ctx, w, r)
+			}
+
+			select {
+			case s <- struct{}{}:
+				defer func() { <-s }()
+			default:
+				w.Header().Set("Retry-After", retry)
+				return ErrOverloaded
+			}
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	a.mw = append(a.mw, m)
+}
+
+// ParseRouteTimeouts parses route deadlines in the form of
+// "METHOD /route=duration", like "GET /v1/users=30s".
+func ParseRouteTimeouts(values []string) (map[string]time.Duration, error) {
+	routes := make(map[string]time.Duration, len(values))
+	for _, value := range values {
+		route, timeout, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("route timeout %q is not in the form of METHOD /route=duration", value)
+		}
+
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok {
+			return nil, fmt.Errorf("route timeout %q is missing the method", value)
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(timeout))
+		if err != nil {
+			return nil, fmt.Errorf("route timeout %q: %w", value, err)
+		}
+
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = d
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+// MaxBodySize is the largest request body in bytes Decode accepts.
+const MaxBodySize = 1 << 20
+
+// Param returns the web call parameters from the request.
+func Param(r *http.Request, key string) string {
+	m := httptreemux.ContextParams(r.Context())
+	return m[key]
+}
+
+// Route returns the route pattern that matched the request, without the
+// parameters expanded.
+func Route(r *http.Request) string {
+	return httptreemux.ContextRoute(r.Context())
+}
+
+// validator is implemented by values that can validate themselves.
+type validator interface {
+	Validate() error
+}
+
+// Decode reads the JSON body of the request into the value and then
+// validates it when the value has a Validate method. Bodies larger than
+// MaxBodySize, other content types, unknown fields and trailing data are
+// rejected with a DecodeError.
+func Decode(r *http.Request, val any) error {
+	ct := r.Header.Get("Content-Type")
+	if media := mediaType(ct); media != "application/json" && !strings.HasSuffix(media, "+json") {
+		return &DecodeError{
+			Status: http.StatusUnsupportedMediaType,
+			Err:    fmt.Errorf("content type %q is not supported, use application/json", ct),
+		}
+	}
+
+	d := json.NewDecoder(http.MaxBytesReader(nil, r.Body, MaxBodySize))
+	d.DisallowUnknownFields()
+
+	if err := d.Decode(val); err != nil {
+		return decodeError(err)
+	}
+
+	if err := d.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return &DecodeError{
+			Status: http.StatusBadRequest,
+			Field:  "body",
+			Err:    errors.New("must contain a single JSON value"),
+		}
+	}
+
+	if v, ok := val.(validator); ok {
+		return v.Validate()
+	}
+
+	return nil
+}
+
+// decodeError converts an error from the JSON decoder into a DecodeError
+// naming the field at fault when it is known.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+
+	de := DecodeError{
+		Status: http.StatusBadRequest,
+		Field:  "body",
+	}
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		de.Err = fmt.Errorf("malformed JSON at offset %d", syntaxErr.Offset)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		de.Err = errors.New("malformed JSON")
+
+	case errors.Is(err, io.EOF):
+		de.Err = errors.New("must not be empty")
+
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			de.Field = typeErr.Field
+		}
+		de.Err = fmt.Errorf("must be of type %s", typeErr.Type)
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		de.Field = strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		de.Err = errors.New("unknown field")
+
+	case errors.As(err, &maxErr):
+		de.Status = http.StatusRequestEntityTooLarge
+		de.Field = ""
+		de.Err = fmt.Errorf("request body must not be larger than %d bytes", maxErr.Limit)
+
+	default:
+		return err
+	}
+
+	return &de
+}
+
+// =============================================================================
+
+// DecodeError is returned when the request body can't be decoded. The Field
+// is set when the problem is with the body or one of its fields, otherwise
+// the Status is the one to respond with.
+type DecodeError struct {
+	Status int
+	Field  string
+	Err    error
+}
+
+// Error implements the error interface.
+func (de *DecodeError) Error() string {
+	if de.Field == "" {
+		return de.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", de.Field, de.Err)
+}
+
+// Unwrap returns the underlying error.
+func (de *DecodeError) Unwrap() error {
+	return de.Err
+}
+
+// IsDecodeError checks if an error of type DecodeError exists.
+func IsDecodeError(err error) bool {
+	var de *DecodeError
+	return errors.As(err, &de)
+}
+
+// GetDecodeError returns a copy of the DecodeError pointer.
+func GetDecodeError(err error) *DecodeError {
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		return nil
+	}
+	return de
+}
@@ -1,33 +1,187 @@
 package web
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 )
 
-// Respond converts a Go value to JSON and sends it to the client.
+// Respond encodes a Go value in the format negotiated with the client and
+// sends it. JSON is used when no format was negotiated or the negotiated
+// encoder can't represent the value. ErrNotAcceptable is returned, with
+// nothing sent, when the client accepts none of the formats, except for
+// error responses which are sent as JSON rather than hidden behind a 406.
 func Respond(ctx context.Context, w http.ResponseWriter, data any, statusCode int) error {
-	SetStatusCode(ctx, statusCode)
-
 	if statusCode == http.StatusNoContent {
+		SetStatusCode(ctx, statusCode)
 		w.WriteHeader(statusCode)
 		return nil
 	}
 
-	w.Header().Set("X-Request-ID", GetTraceID(ctx))
+	enc := jsonEncoder
+	if v, ok := ctx.Value(key).(*Values); ok {
+		switch {
+		case v.encoder.Encode != nil:
+			enc = v.encoder
+			w.Header().Add("Vary", "Accept")
+		case v.notAcceptable:
+			w.Header().Add("Vary", "Accept")
+			if statusCode < http.StatusBadRequest {
+				return ErrNotAcceptable
+			}
+		}
+	}
+
+	SetStatusCode(ctx, statusCode)
+
+	err := respond(ctx, w, enc, data, statusCode)
+	if errors.Is(err, ErrUnsupportedValue) && enc.ContentType != jsonEncoder.ContentType {
+		return respond(ctx, w, jsonEncoder, data, statusCode)
+	}
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
+	return err
+}
+
+// RespondJSON encodes a Go value as JSON and sends it with the specified
+// content type, for the media types based on JSON like
+// application/problem+json. The content negotiated with the client is
+// ignored.
+func RespondJSON(ctx context.Context, w http.ResponseWriter, data any, statusCode int, contentType string) error {
+	SetStatusCode(ctx, statusCode)
+
+	var b bytes.Buffer
+	if err := encodeJSON(&b, data); err != nil {
 		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
+
+	return write(ctx, w, b.Bytes(), statusCode)
+}
+
+// respond sends the value encoded with the specified encoder.
+func respond(ctx context.Context, w http.ResponseWriter, enc Encoder, data any, statusCode int) error {
+	if enc.Stream {
+		return stream(ctx, w, enc, data, statusCode)
+	}
+
+	var b bytes.Buffer
+	if err := enc.Encode(&b, data); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", enc.ContentType)
+
+	return write(ctx, w, b.Bytes(), statusCode)
+}
+
+// write sends the body to the client, compressing it when compression is
+// enabled and the client accepts it.
+func write(ctx context.Context, w http.ResponseWriter, data []byte, statusCode int) error {
+	if v, ok := ctx.Value(key).(*Values); ok && v.compressMin > 0 {
+		h := w.Header()
+		h.Add("Vary", "Accept-Encoding")
+
+		if v.encoding != "" && len(data) >= v.compressMin && h.Get("Content-Encoding") == "" {
+			cdata, err := compress(v.encoding, data)
+			if err != nil {
+				return err
+			}
+
+			h.Set("Content-Encoding", v.encoding)
+			h.Del("Content-Length")
+			data = cdata
+		}
+	}
+
 	w.WriteHeader(statusCode)
 
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// stream sends the value to the client as it is encoded. The response is
+// compressed regardless of its size when compression is enabled and the
+// client accepts it, since the size isn't known up front.
+func stream(ctx context.Context, w http.ResponseWriter, enc Encoder, data any, statusCode int) error {
+	var encoding string
+	var vary bool
+	if v, ok := ctx.Value(key).(*Values); ok && v.compressMin > 0 {
+		vary = true
+		if w.Header().Get("Content-Encoding") == "" {
+			encoding = v.encoding
+		}
+	}
+
+	hw := headerWriter{
+		w:          w,
+		statusCode: statusCode,
+		before: func(h http.Header) {
+			h.Set("Content-Type", enc.ContentType)
+			if vary {
+				h.Add("Vary", "Accept-Encoding")
+			}
+			if encoding != "" {
+				h.Set("Content-Encoding", encoding)
+				h.Del("Content-Length")
+			}
+		},
+	}
+
+	var out io.Writer = &hw
+	var zw io.WriteCloser
+	if encoding != "" {
+		var release func()
+		var err error
+		if zw, release, err = newCompressor(encoding, &hw); err != nil {
+			return err
+		}
+		defer release()
+		out = zw
+	}
+
+	if err := enc.Encode(out, data); err != nil {
 		return err
 	}
 
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	hw.writeHeader()
+
 	return nil
 }
+
+// headerWriter writes the status code and headers of the response on the
+// first write, so an encoder can still fail before anything is sent.
+type headerWriter struct {
+	w          http.ResponseWriter
+	statusCode int
+	before     func(h http.Header)
+	wrote      bool
+}
+
+// Write implements the io.Writer interface.
+func (hw *headerWriter) Write(p []byte) (int, error) {
+	hw.writeHeader()
+	return hw.w.Write(p)
+}
+
+// writeHeader sends the status code once.
+func (hw *headerWriter) writeHeader() {
+	if hw.wrote {
+		return
+	}
+	hw.wrote = true
+
+	hw.before(hw.w.Header())
+	hw.w.WriteHeader(hw.statusCode)
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerEvent represents an event sent on an event stream. Data is sent as is
+// when it's a string or a byte slice and encoded as JSON otherwise. Retry
+// tells the client how long to wait before reconnecting.
+type ServerEvent struct {
+	ID    string
+	Name  string
+	Data  any
+	Retry time.Duration
+}
+
+// EventStream sends server-sent events to the client. Every event is flushed
+// as soon as it's written.
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+type EventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// NewEventStream starts an event stream on the response. The write deadline
+// of the server is removed, since the stream is expected to outlive it, and
+// the headers are flushed so the client knows the stream is open.
+func NewEventStream(ctx context.Context, w http.ResponseWriter) (*EventStream, error) {
+	rc := http.NewResponseController(w)
+
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, fmt.Errorf("clearing write deadline: %w", err)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+
+	SetStatusCode(ctx, http.StatusOK)
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		return nil, fmt.Errorf("flushing headers: %w", err)
+	}
+
+	es := EventStream{
+		w:  w,
+		rc: rc,
+	}
+
+	return &es, nil
+}
+
+// Send writes the event to the client.
+func (es *EventStream) Send(evt ServerEvent) error {
+	var data []byte
+	switch v := evt.Data.(type) {
+	case nil:
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return fmt.Errorf("encoding event data: %w", err)
+		}
+	}
+
+	var b bytes.Buffer
+	if evt.ID != "" {
+		b.WriteString("id: " + singleLine(evt.ID) + "\n")
+	}
+	if evt.Name != "" {
+		b.WriteString("event: " + singleLine(evt.Name) + "\n")
+	}
+	if evt.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(evt.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		b.WriteString("data: ")
+		b.Write(bytes.TrimSuffix(line, []byte("\r")))
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+
+	return es.write(b.Bytes())
+}
+
+// Heartbeat writes a comment to the client. Comments are ignored by the
+// client but keep proxies from closing an idle connection and detect a client
+// that went away.
+func (es *EventStream) Heartbeat() error {
+	return es.write([]byte(":\n\n"))
+}
+
+// Serve sends the events received on the channel until the channel is closed
+// or the context is canceled, which happens when the client disconnects. A
+// heartbeat is sent at every interval, a zero interval disables them.
+func (es *EventStream) Serve(ctx context.Context, events <-chan ServerEvent, heartbeat time.Duration) error {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			if err := es.Send(evt); err != nil {
+				return err
+			}
+
+		case <-tick:
+			if err := es.Heartbeat(); err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// write sends the bytes to the client and flushes them.
+func (es *EventStream) write(p []byte) error {
+	if _, err := es.w.Write(p); err != nil {
+		return err
+	}
+
+	return es.rc.Flush()
+}
+
+// =============================================================================
+
+// LastEventID returns the ID of the last event a reconnecting client
+// received. Browsers send it in the Last-Event-ID header, the lastEventId
+// query parameter is accepted for clients that can't set headers.
+func LastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+
+	return r.URL.Query().Get("lastEventId")
+}
+
+// singleLine removes the line breaks that would end a field early.
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,270 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Set of headers used to carry the trace context between services.
+const (
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+	HeaderRequestID   = "X-Request-ID"
+)
+
+// maxRequestIDLen limits the size of a request id accepted from a client.
+const maxRequestIDLen = 128
+
+// newTraceValues constructs the trace related values for a request. The trace
+// id of a valid W3C traceparent header is used first, then a valid
+// X-Request-ID header, otherwise a new trace id is minted.
+func newTraceValues(r *http.Request) Values {
+	v := Values{
+		SpanID:     newSpanID(),
+		TraceFlags: "01",
+	}
+
+	if traceID, flags, ok := parseTraceParent(r.Header.Get(HeaderTraceParent)); ok {
+		v.TraceID = traceID
+		v.TraceFlags = flags
+		v.TraceState = parseTraceState(r.Header.Values(HeaderTraceState))
+		return v
+	}
+
+	if id := r.Header.Get(HeaderRequestID); validRequestID(id) {
+		v.TraceID = id
+		return v
+	}
+
+	v.TraceID = uuid.NewString()
+
+	return v
+}
+
+// startSpan starts the server span for the request as a child of the remote
+// span of a traceparent header. When the span has valid ids, recorded or not,
+// they replace the ones minted for the request so the logs, the traceparent
+// sent on and the traces share the same trace id. A trace id taken from
+// X-Request-ID is kept and recorded on the span. A tracer that starts no span
+// of its own hands back the remote parent, whose ids are left alone.
+func (a *App) startSpan(r *http.Request, path string, v *Values) (context.Context, trace.Span) {
+	ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	ctx, span := a.tracer.Start(ctx, "foundation.web.request",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", path),
+		),
+	)
+
+	v.Tracer = a.tracer
+
+	sc := span.SpanContext()
+	if !sc.IsValid() || sc.IsRemote() {
+		return ctx, span
+	}
+
+	switch {
+	case r.Header.Get(HeaderTraceParent) == "" && v.TraceID == r.Header.Get(HeaderRequestID):
+		span.SetAttributes(attribute.String("http.request_id", v.TraceID))
+	default:
+		v.TraceID = sc.TraceID().String()
+	}
+
+	v.SpanID = sc.SpanID().String()
+	v.TraceFlags = sc.TraceFlags().String()
+
+	return ctx, span
+}
+
+// AddSpan adds an OpenTelemetry span to the trace of the request in the
+// context. A non recording span is returned when tracing isn't configured.
+func AddSpan(ctx context.Context, spanName string, keyValues ...attribute.KeyValue) (context.Context, trace.Span) {
+	v, ok := ctx.Value(key).(*Values)
+	if !ok || v.Tracer == nil {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+
+	ctx, span := v.Tracer.Start(ctx, spanName, trace.WithAttributes(keyValues...))
+
+	return ctx, span
+}
+
+// TraceParent returns the W3C traceparent value for the trace in the context
+// using the current span as the parent. An empty string is returned when the
+// trace id can't be represented as a W3C trace id.
+func TraceParent(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && !sc.IsRemote() {
+		return fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+	}
+
+	v := GetValues(ctx)
+
+	traceID := strings.ReplaceAll(v.TraceID, "-", "")
+	if !isHex(traceID, 32) || v.SpanID == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", traceID, v.SpanID, v.TraceFlags)
+}
+
+// setTraceHeaders writes the trace context into the specified headers.
+func setTraceHeaders(ctx context.Context, h http.Header) {
+	v := GetValues(ctx)
+
+	h.Set(HeaderRequestID, v.TraceID)
+
+	if tp := TraceParent(ctx); tp != "" {
+		h.Set(HeaderTraceParent, tp)
+
+		if v.TraceState != "" {
+			h.Set(HeaderTraceState, v.TraceState)
+		}
+	}
+}
+
+// =============================================================================
+
+// NewClient constructs a http client that propagates the trace context of the
+// request context on every outgoing call. A nil client uses the defaults.
+func NewClient(client *http.Client) *http.Client {
+	c := http.Client{}
+	if client != nil {
+		c = *client
+	}
+
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	c.Transport = &traceTransport{
+		base: transport,
+	}
+
+	return &c
+}
+
+// traceTransport is a http.RoundTripper that adds the trace headers.
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if _, ok := ctx.Value(key).(*Values); !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(ctx)
+	setTraceHeaders(ctx, req.Header)
+
+	return t.base.RoundTrip(req)
+}
+
+// =============================================================================
+
+// parseTraceParent validates a W3C traceparent header value and returns the
+// trace id and flags.
+// https://www.w3.org/TR/trace-context/#traceparent-header
+func parseTraceParent(value string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return "", "", false
+	}
+
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	switch {
+	case !isHex(version, 2) || version == "ff":
+		return "", "", false
+	case version == "00" && len(parts) != 4:
+		return "", "", false
+	case !isHex(traceID, 32) || traceID == strings.Repeat("0", 32):
+		return "", "", false
+	case !isHex(parentID, 16) || parentID == strings.Repeat("0", 16):
+		return "", "", false
+	case !isHex(flags, 2):
+		return "", "", false
+	}
+
+	return traceID, flags, true
+}
+
+// parseTraceState joins the tracestate headers into a single value and drops
+// it when it exceeds the limits of the specification.
+// https://www.w3.org/TR/trace-context/#tracestate-header
+func parseTraceState(values []string) string {
+	var members []string
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			member = strings.TrimSpace(member)
+			if member == "" {
+				continue
+			}
+			if !strings.Contains(member, "=") {
+				return ""
+			}
+			members = append(members, member)
+		}
+	}
+
+	if len(members) > 32 {
+		return ""
+	}
+
+	return strings.Join(members, ",")
+}
+
+// validRequestID checks the request id is made of printable ascii characters
+// and is of a reasonable size so it is safe to log and echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newSpanID generates a W3C parent id for the current request.
+func newSpanID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// isHex reports if s is made of n lowercase hex characters.
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,194 @@
+package web_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/farmani/service/foundation/web"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// exporter keeps the exported spans in memory.
+type exporter struct {
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (e *exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *exporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *exporter) span(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, span := range e.spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+
+	t.Fatalf("span %q was not exported", name)
+	return nil
+}
+
+// =============================================================================
+
+const (
+	remoteTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteSpanID  = "00f067aa0ba902b7"
+)
+
+// serve runs a request through an app whose handler adds a span and calls a
+// downstream service. It returns the exporter, the response and the
+// traceparent the downstream service received.
+func serve(t *testing.T, sampler sdktrace.Sampler, traceParent string) (*exporter, *http.Response, string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var downstream string
+	ds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		downstream = r.Header.Get(web.HeaderTraceParent)
+	}))
+	t.Cleanup(ds.Close)
+
+	exp := exporter{}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(&exp),
+		sdktrace.WithSampler(sampler),
+	)
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+
+	client := web.NewClient(nil)
+
+	app := web.NewApp(nil, tp.Tracer("test"))
+	app.Handle(http.MethodGet, "/test", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		ctx, span := web.AddSpan(ctx, "test.downstream")
+		defer span.End()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ds.URL, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/test", nil)
+	if traceParent != "" {
+		r.Header.Set(web.HeaderTraceParent, traceParent)
+	}
+	w := httptest.NewRecorder()
+
+	app.ServeHTTP(w, r)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return &exp, w.Result(), downstream
+}
+
+func TestTraceParentPropagation(t *testing.T) {
+	exp, resp, downstream := serve(t, sdktrace.AlwaysSample(), fmt.Sprintf("00-%s-%s-01", remoteTraceID, remoteSpanID))
+
+	server := exp.span(t, "foundation.web.request")
+	child := exp.span(t, "test.downstream")
+
+	if got := server.SpanContext().TraceID().String(); got != remoteTraceID {
+		t.Fatalf("got server trace id %s, exp %s", got, remoteTraceID)
+	}
+
+	if got := server.Parent(); !got.IsRemote() || got.SpanID().String() != remoteSpanID {
+		t.Fatalf("got server parent %s remote[%t], exp remote %s", got.SpanID(), got.IsRemote(), remoteSpanID)
+	}
+
+	if server.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("got server span kind %s, exp %s", server.SpanKind(), trace.SpanKindServer)
+	}
+
+	if got := child.Parent().SpanID(); got != server.SpanContext().SpanID() {
+		t.Fatalf("got child parent %s, exp the server span %s", got, server.SpanContext().SpanID())
+	}
+
+	expDownstream := fmt.Sprintf("00-%s-%s-01", remoteTraceID, child.SpanContext().SpanID())
+	if downstream != expDownstream {
+		t.Fatalf("got downstream traceparent %q, exp %q", downstream, expDownstream)
+	}
+
+	expResponse := fmt.Sprintf("00-%s-%s-01", remoteTraceID, server.SpanContext().SpanID())
+	if got := resp.Header.Get(web.HeaderTraceParent); got != expResponse {
+		t.Fatalf("got response traceparent %q, exp %q", got, expResponse)
+	}
+
+	if got := resp.Header.Get(web.HeaderRequestID); got != remoteTraceID {
+		t.Fatalf("got request id %q, exp %q", got, remoteTraceID)
+	}
+}
+
+func TestTraceParentNewTrace(t *testing.T) {
+	exp, resp, downstream := serve(t, sdktrace.AlwaysSample(), "")
+
+	server := exp.span(t, "foundation.web.request")
+	child := exp.span(t, "test.downstream")
+
+	if server.Parent().IsValid() {
+		t.Fatalf("expected a root server span, got parent %s", server.Parent().SpanID())
+	}
+
+	traceID := server.SpanContext().TraceID().String()
+	if got := child.SpanContext().TraceID().String(); got != traceID {
+		t.Fatalf("got child trace id %s, exp %s", got, traceID)
+	}
+
+	expDownstream := fmt.Sprintf("00-%s-%s-01", traceID, child.SpanContext().SpanID())
+	if downstream != expDownstream {
+		t.Fatalf("got downstream traceparent %q, exp %q", downstream, expDownstream)
+	}
+
+	if got := resp.Header.Get(web.HeaderRequestID); got != traceID {
+		t.Fatalf("got request id %q, exp the trace id %q", got, traceID)
+	}
+}
+
+func TestTraceParentNotSampled(t *testing.T) {
+	exp, resp, downstream := serve(t, sdktrace.ParentBased(sdktrace.AlwaysSample()), fmt.Sprintf("00-%s-%s-00", remoteTraceID, remoteSpanID))
+
+	if len(exp.spans) != 0 {
+		t.Fatalf("got %d exported spans for an unsampled trace, exp 0", len(exp.spans))
+	}
+
+	// The ids of the unrecorded span are still sent on, so the trace isn't
+	// broken for the services that do record it.
+	for _, value := range []string{downstream, resp.Header.Get(web.HeaderTraceParent)} {
+		if len(value) != 55 || value[3:35] != remoteTraceID || value[53:] != "00" {
+			t.Fatalf("got traceparent %q, exp trace id %s with flags 00", value, remoteTraceID)
+		}
+
+		if value[36:52] == remoteSpanID {
+			t.Fatalf("got traceparent %q, exp a span id of this service", value)
+		}
+	}
+}
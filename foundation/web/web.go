@@ -5,11 +5,14 @@ import (
 	"errors"
 	"net/http"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // A Handler is a type that handles a http request within our own little mini
@@ -21,15 +24,25 @@ type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) e
 // data/logic on this App struct.
 type App struct {
 	*httptreemux.ContextMux
-	shutdown chan os.Signal
-	mw       []Middleware
+	shutdown    chan os.Signal
+	mw          []Middleware
+	tracer      trace.Tracer
+	compressMin int
+	endpoints   endpoints
 }
 
-func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
+// NewApp creates an App value that handle a set of routes for the application.
+// A nil tracer disables tracing.
+func NewApp(shutdown chan os.Signal, tracer trace.Tracer, mw ...Middleware) *App {
+	if tracer == nil {
+		tracer = trace.NewNoopTracerProvider().Tracer("")
+	}
+
 	return &App{
 		ContextMux: httptreemux.NewContextMux(),
 		shutdown:   shutdown,
 		mw:         mw,
+		tracer:     tracer,
 	}
 }
 
@@ -39,29 +52,82 @@ func (a *App) SignalShutdown() {
 	a.shutdown <- syscall.SIGTERM
 }
 
+// EnableCORS adds the CORS middleware to every route and uses it to answer the
+// preflight requests for every registered path, which would otherwise get a
+// 405 from the router. The preflight requests go through all of the global
+// middleware, so they are logged and their errors handled like any other
+// request. It must be called before any route is registered.
+func (a *App) EnableCORS(mw Middleware) {
+	a.mw = append(a.mw, mw)
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	// The middleware enabled after this call is only known once the app
+	// serves requests.
+	var once sync.Once
+	a.ContextMux.OptionsHandler = func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		once.Do(func() { handler = wrapMiddleware(a.mw, handler) })
+
+		a.serve(w, r, Route(r), handler)
+	}
+}
+
 // Handle sets a handler function for a given HTTP method and path pair
-// to the application server mux.
-func (a *App) Handle(method string, path string, handler Handler, mw ...Middleware) {
+// to the application server mux. The returned endpoint can be used to
+// document the route.
+func (a *App) Handle(method string, path string, handler Handler, mw ...Middleware) *Endpoint {
 	// First wrap handler specific middleware around this handler.
 	handler = wrapMiddleware(mw, handler)   // specific middleware for this handler
 	handler = wrapMiddleware(a.mw, handler) // global middleware for all handlers
 
 	h := func(w http.ResponseWriter, r *http.Request) {
-		v := Values{
-			TraceID: uuid.NewString(),
-			Now:     time.Now().UTC(),
-		}
-		ctx := context.WithValue(r.Context(), key, &v)
-
-		if err := handler(ctx, w, r); err != nil {
-			if validateShutdown(err) {
-				a.SignalShutdown()
-				return
-			}
-		}
+		a.serve(w, r, path, handler)
 	}
 
 	a.ContextMux.Handle(method, path, h)
+
+	return a.endpoints.add(method, path)
+}
+
+// serve runs the request through the handler, which is already wrapped in the
+// middleware, with the values of the request in the context.
+func (a *App) serve(w http.ResponseWriter, r *http.Request, path string, handler Handler) {
+	v := newTraceValues(r)
+	v.Now = time.Now().UTC()
+
+	if a.compressMin > 0 {
+		v.encoding = negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		v.compressMin = a.compressMin
+	}
+
+	ctx, span := a.startSpan(r, path, &v)
+	defer span.End()
+
+	ctx = context.WithValue(ctx, key, &v)
+
+	setTraceHeaders(ctx, w.Header())
+
+	// The outcome of the negotiation is only acted on by Respond, so
+	// routes that answer in their own media type, like event streams,
+	// are served whatever the client accepts.
+	enc, ok := negotiateEncoder(r)
+	v.encoder, v.notAcceptable = enc, !ok
+
+	err := handler(ctx, w, r)
+
+	span.SetAttributes(attribute.Int("http.status_code", v.StatusCode))
+	if v.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(v.StatusCode))
+	}
+
+	if err != nil {
+		if validateShutdown(err) {
+			a.SignalShutdown()
+			return
+		}
+	}
 }
 
 // validateShutdown validates the error for special conditions that do not